@@ -1,16 +1,14 @@
 package config
 
 import (
-	"github.com/ForTheChurch/buildforthechurch/internal/gloo"
-	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
-	"github.com/ForTheChurch/buildforthechurch/internal/scraper"
+	"github.com/ForTheChurch/buildforthechurch/internal/worker"
 )
 
 type Config struct {
-	Gloo       gloo.Config
-	Scraper    scraper.FirecrawlConfig
-	PayloadCMS payloadcms.Config
+	worker.Config
 
-	Port        string `env:"AGENT_API_PORT,required"`
-	AgentAPIKey string `env:"AGENT_API_KEY,required"`
+	Port string `env:"AGENT_API_PORT,required"`
+	// AgentAPIKeys are more API keys by the name of their holder, e.g. "web:key1,scheduler:key2".
+	// The name is recorded in the task history, AgentAPIKey is named "default".
+	AgentAPIKeys map[string]string `env:"AGENT_API_KEYS"`
 }
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"time"
+
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/middleware"
 	"github.com/ForTheChurch/buildforthechurch/cmd/api/services"
 	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
 	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
@@ -17,8 +20,11 @@ func NewPageHandler(services *services.Services) *PageHandler {
 
 func (h *PageHandler) ConvertSinglePage(c *gin.Context) {
 	type params struct {
-		URL    string `json:"url" binding:"required"`
-		PageID string `json:"pageId" binding:"required"`
+		URL         string `json:"url" binding:"required"`
+		PageID      string `json:"pageId" binding:"required"`
+		CallbackURL string `json:"callbackUrl" binding:"omitempty,url"`
+		// TimeoutSeconds overrides how long the task may run, up to the server maximum
+		TimeoutSeconds int `json:"timeoutSeconds" binding:"omitempty,min=1"`
 	}
 
 	var p params
@@ -29,18 +35,29 @@ func (h *PageHandler) ConvertSinglePage(c *gin.Context) {
 
 	id, err := h.services.GetAgentTaskManager().QueueTask(agenttask.NewConvertPageTask(
 		p.URL, p.PageID,
-		h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()))
+		h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()),
+		agenttaskmanager.WithCallbackURL(p.CallbackURL),
+		agenttaskmanager.WithTimeout(time.Duration(p.TimeoutSeconds)*time.Second),
+		agenttaskmanager.WithIdempotencyKey(c.GetHeader("Idempotency-Key")),
+		agenttaskmanager.WithRequestedBy(c.GetString(middleware.IdentityKey)))
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		respondQueueError(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"task_status": agenttaskmanager.TaskStatusQueued, "task_id": id})
+	respondQueued(c, h.services.GetAgentTaskManager(), id)
 }
 
 func (h *PageHandler) ConvertWholeSite(c *gin.Context) {
 	type params struct {
-		URL string `json:"url" binding:"required"`
+		URL         string `json:"url" binding:"required"`
+		CallbackURL string `json:"callbackUrl" binding:"omitempty,url"`
+		// TimeoutSeconds overrides how long the task may run, up to the server maximum
+		TimeoutSeconds int `json:"timeoutSeconds" binding:"omitempty,min=1"`
+		// FailureThreshold is the share of pages that may fail before the conversion gives up
+		FailureThreshold *float64 `json:"failureThreshold" binding:"omitempty,min=0,max=1"`
+		// MaxParallelPages overrides how many pages are converted at the same time, up to the number of workers
+		MaxParallelPages int `json:"maxParallelPages" binding:"omitempty,min=1"`
 	}
 
 	var p params
@@ -50,21 +67,17 @@ func (h *PageHandler) ConvertWholeSite(c *gin.Context) {
 	}
 
 	id, err := h.services.GetAgentTaskManager().QueueTask(agenttask.NewConvertWholeSiteTask(
-		p.URL, h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()))
+		p.URL, h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()),
+		agenttaskmanager.WithCallbackURL(p.CallbackURL),
+		agenttaskmanager.WithTimeout(time.Duration(p.TimeoutSeconds)*time.Second),
+		agenttaskmanager.WithFailureThreshold(p.FailureThreshold),
+		agenttaskmanager.WithMaxParallelChildren(p.MaxParallelPages),
+		agenttaskmanager.WithIdempotencyKey(c.GetHeader("Idempotency-Key")),
+		agenttaskmanager.WithRequestedBy(c.GetString(middleware.IdentityKey)))
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		respondQueueError(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"task_status": agenttaskmanager.TaskStatusQueued, "task_id": id})
-}
-
-func (h *PageHandler) GetTaskStatus(c *gin.Context) {
-	id := c.Param("id")
-	status, ok := h.services.GetAgentTaskManager().GetTaskStatus(id)
-	if !ok {
-		c.JSON(404, gin.H{"error": "task not found"})
-		return
-	}
-	c.JSON(200, gin.H{"task_status": status})
+	respondQueued(c, h.services.GetAgentTaskManager(), id)
 }
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"strings"
+	"time"
 
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/middleware"
 	"github.com/ForTheChurch/buildforthechurch/cmd/api/services"
 	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
 	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
@@ -19,8 +21,11 @@ func NewPostHandler(services *services.Services) *PostHandler {
 
 func (h *PostHandler) ApplyYoutubeTranscript(c *gin.Context) {
 	type params struct {
-		URL    string `json:"url" binding:"required"`
-		PostID string `json:"postId" binding:"required"`
+		URL         string `json:"url" binding:"required"`
+		PostID      string `json:"postId" binding:"required"`
+		CallbackURL string `json:"callbackUrl" binding:"omitempty,url"`
+		// TimeoutSeconds overrides how long the task may run, up to the server maximum
+		TimeoutSeconds int `json:"timeoutSeconds" binding:"omitempty,min=1"`
 	}
 
 	var p params
@@ -35,11 +40,15 @@ func (h *PostHandler) ApplyYoutubeTranscript(c *gin.Context) {
 	}
 
 	id, err := h.services.GetAgentTaskManager().QueueTask(agenttask.NewYoutubeTranscriptTask(
-		p.URL, p.PostID, h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()))
+		p.URL, p.PostID, h.services.GetScraper(), h.services.GetPayloadCMSClient(), h.services.GetLLM()),
+		agenttaskmanager.WithCallbackURL(p.CallbackURL),
+		agenttaskmanager.WithTimeout(time.Duration(p.TimeoutSeconds)*time.Second),
+		agenttaskmanager.WithIdempotencyKey(c.GetHeader("Idempotency-Key")),
+		agenttaskmanager.WithRequestedBy(c.GetString(middleware.IdentityKey)))
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		respondQueueError(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"task_status": agenttaskmanager.TaskStatusQueued, "task_id": id})
+	respondQueued(c, h.services.GetAgentTaskManager(), id)
 }
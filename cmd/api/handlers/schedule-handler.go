@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/middleware"
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/services"
+	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	services *services.Services
+}
+
+func NewScheduleHandler(services *services.Services) *ScheduleHandler {
+	return &ScheduleHandler{services: services}
+}
+
+// scheduleParams is the body of the routes that create or update a schedule
+type scheduleParams struct {
+	Name string `json:"name"`
+	// Cron is a 5 field cron expression or a descriptor like "@daily"
+	Cron     string `json:"cron" binding:"required"`
+	Timezone string `json:"timezone"`
+	// Until stops the schedule after this time
+	Until *time.Time `json:"until"`
+	// Enabled defaults to true
+	Enabled        *bool           `json:"enabled"`
+	TaskType       string          `json:"taskType" binding:"required"`
+	Params         json.RawMessage `json:"params" binding:"required"`
+	TimeoutSeconds int             `json:"timeoutSeconds" binding:"omitempty,min=1"`
+	CallbackURL    string          `json:"callbackUrl" binding:"omitempty,url"`
+}
+
+func (p scheduleParams) schedule() agenttaskmanager.Schedule {
+	return agenttaskmanager.Schedule{
+		Name:        p.Name,
+		Cron:        p.Cron,
+		Timezone:    p.Timezone,
+		Until:       p.Until,
+		Enabled:     p.Enabled == nil || *p.Enabled,
+		TaskType:    p.TaskType,
+		Params:      p.Params,
+		Timeout:     time.Duration(p.TimeoutSeconds) * time.Second,
+		CallbackURL: p.CallbackURL,
+	}
+}
+
+func scheduleResponse(schedule agenttaskmanager.Schedule) gin.H {
+	return gin.H{
+		"schedule_id":     schedule.ID,
+		"name":            schedule.Name,
+		"cron":            schedule.Cron,
+		"timezone":        schedule.Timezone,
+		"until":           schedule.Until,
+		"enabled":         schedule.Enabled,
+		"task_type":       schedule.TaskType,
+		"params":          schedule.Params,
+		"timeout_seconds": int(schedule.Timeout.Seconds()),
+		"callback_url":    schedule.CallbackURL,
+		"created_by":      schedule.CreatedBy,
+		"created_at":      schedule.CreatedAt,
+		"updated_at":      schedule.UpdatedAt,
+		"next_run_at":     schedule.NextRunAt(),
+		"last_run_at":     schedule.LastRunAt,
+		"last_task_id":    schedule.LastTaskID,
+		"last_error":      schedule.LastError,
+	}
+}
+
+// respondScheduleError writes the response for an error from the scheduler
+func respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, agenttaskmanager.ErrScheduleNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, agenttaskmanager.ErrInvalidSchedule):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.services.GetScheduler().List()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]gin.H, 0, len(schedules))
+	for _, schedule := range schedules {
+		response = append(response, scheduleResponse(schedule))
+	}
+	c.JSON(200, gin.H{"schedules": response})
+}
+
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, ok := h.services.GetScheduler().Get(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": agenttaskmanager.ErrScheduleNotFound.Error()})
+		return
+	}
+	c.JSON(200, scheduleResponse(schedule))
+}
+
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var p scheduleParams
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := p.schedule()
+	schedule.CreatedBy = c.GetString(middleware.IdentityKey)
+	schedule, err := h.services.GetScheduler().Create(schedule)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(200, scheduleResponse(schedule))
+}
+
+// UpdateSchedule replaces the definition of a schedule
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	var p scheduleParams
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.services.GetScheduler().Update(c.Param("id"), p.schedule())
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(200, scheduleResponse(schedule))
+}
+
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.services.GetScheduler().Delete(id); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"schedule_id": id, "deleted": true})
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/middleware"
+	"github.com/ForTheChurch/buildforthechurch/cmd/api/services"
+	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/gin-gonic/gin"
+)
+
+// statusPollInterval is how often an event stream reads the status of its task from the store
+const statusPollInterval = 2 * time.Second
+
+type TaskHandler struct {
+	services *services.Services
+}
+
+func NewTaskHandler(services *services.Services) *TaskHandler {
+	return &TaskHandler{services: services}
+}
+
+// respondQueueError writes the response for an error from QueueTask
+func respondQueueError(c *gin.Context, err error) {
+	var queueFull *agenttaskmanager.QueueFullError
+	if errors.As(err, &queueFull) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(queueFull.RetryAfter.Seconds()))))
+		c.JSON(429, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, agenttaskmanager.ErrShuttingDown) {
+		c.JSON(503, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
+// respondQueued responds with the status of a task returned by QueueTask, which is not queued
+// when the submission was a duplicate of an earlier task
+func respondQueued(c *gin.Context, manager *agenttaskmanager.AgentTaskManager, id string) {
+	status, ok := manager.GetTaskStatus(id)
+	if !ok {
+		status = agenttaskmanager.TaskStatusQueued
+	}
+	c.JSON(200, gin.H{"task_status": status, "task_id": id})
+}
+
+// queueInfos reads where the queued tasks among tasks stand, the queue is only read if there are some
+func queueInfos(manager *agenttaskmanager.AgentTaskManager, tasks ...agenttaskmanager.TaskRecord) map[string]agenttaskmanager.QueueInfo {
+	queued := slices.ContainsFunc(tasks, func(task agenttaskmanager.TaskRecord) bool {
+		return task.Status == agenttaskmanager.TaskStatusQueued
+	})
+	if !queued {
+		return nil
+	}
+	return manager.QueueInfos()
+}
+
+// taskResponse is the response for a task, queue is the result of AgentTaskManager.QueueInfos
+func taskResponse(task agenttaskmanager.TaskRecord, queue map[string]agenttaskmanager.QueueInfo) gin.H {
+	response := gin.H{
+		"task_id":          task.ID,
+		"task_type":        task.Type,
+		"params":           task.Params,
+		"task_status":      task.Status,
+		"priority":         task.Priority,
+		"created_at":       task.CreatedAt,
+		"started_at":       task.StartedAt,
+		"finished_at":      task.FinishedAt,
+		"attempts":         task.Attempts,
+		"max_attempts":     task.MaxAttempts,
+		"timeout_seconds":  int(task.Timeout.Seconds()),
+		"progress":         task.Progress,
+		"error":            task.Error,
+		"error_code":       task.ErrorCode,
+		"error_chain":      task.ErrorChain,
+		"result":           task.Result(),
+		"documents":        task.Documents,
+		"parent_id":        task.ParentID,
+		"requested_by":     task.RequestedBy,
+		"worker_id":        task.WorkerID,
+		"dead_lettered_at": task.DeadLetteredAt,
+		"replay_of":        task.ReplayOf,
+		"replayed_by":      task.ReplayedBy,
+		"child_ids":        task.ChildIDs,
+		"children":         task.Children,
+		"checkpoints":      task.Checkpoints,
+		"callback": gin.H{
+			"url":        task.CallbackURL,
+			"deliveries": task.CallbackDeliveries,
+		},
+		"queue_position":     nil,
+		"estimated_start_at": nil,
+	}
+
+	if info, ok := queue[task.ID]; ok {
+		response["queue_position"] = info.Position
+		response["estimated_start_at"] = info.EstimatedStartAt
+	} else if task.NextAttemptAt != nil {
+		// Waiting for a retry
+		response["estimated_start_at"] = task.NextAttemptAt
+	}
+	return response
+}
+
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	id := c.Param("id")
+	manager := h.services.GetAgentTaskManager()
+	task, ok := manager.GetTask(id)
+	if !ok {
+		c.JSON(404, gin.H{"error": "task not found"})
+		return
+	}
+	c.JSON(200, taskResponse(task, queueInfos(manager, task)))
+}
+
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	filter, ok := taskFilter(c)
+	if !ok {
+		return
+	}
+	respondTaskPage(c, h.services.GetAgentTaskManager(), filter)
+}
+
+// ListDeadTasks lists the tasks that failed for good and weren't replayed yet
+func (h *TaskHandler) ListDeadTasks(c *gin.Context) {
+	filter, ok := taskFilter(c)
+	if !ok {
+		return
+	}
+	filter.DeadLettered = true
+	respondTaskPage(c, h.services.GetAgentTaskManager(), filter)
+}
+
+// taskFilter reads the filter of a task listing from the query, it responds with 400 if the query is invalid
+func taskFilter(c *gin.Context) (agenttaskmanager.TaskFilter, bool) {
+	filter := agenttaskmanager.TaskFilter{
+		Status:   agenttaskmanager.TaskStatus(c.Query("status")),
+		Type:     c.Query("type"),
+		ParentID: c.Query("parent_id"),
+		Cursor:   c.Query("cursor"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "since must be an RFC 3339 time"})
+			return filter, false
+		}
+		filter.Since = t
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			c.JSON(400, gin.H{"error": "limit must be a positive number"})
+			return filter, false
+		}
+		filter.Limit = n
+	}
+
+	return filter, true
+}
+
+func respondTaskPage(c *gin.Context, manager *agenttaskmanager.AgentTaskManager, filter agenttaskmanager.TaskFilter) {
+	page, err := manager.ListTasks(filter)
+	if err != nil {
+		if errors.Is(err, agenttaskmanager.ErrInvalidCursor) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	queue := queueInfos(manager, page.Tasks...)
+	tasks := make([]gin.H, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		tasks = append(tasks, taskResponse(task, queue))
+	}
+	c.JSON(200, gin.H{"tasks": tasks, "next_cursor": page.NextCursor})
+}
+
+func (h *TaskHandler) CancelTask(c *gin.Context) {
+	id := c.Param("id")
+	manager := h.services.GetAgentTaskManager()
+
+	if err := manager.Cancel(id, c.GetString(middleware.IdentityKey)); err != nil {
+		switch {
+		case errors.Is(err, agenttaskmanager.ErrTaskNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, agenttaskmanager.ErrTaskFinished):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Running tasks stay running until they notice the cancellation
+	status, _ := manager.GetTaskStatus(id)
+	c.JSON(200, gin.H{"task_status": status, "task_id": id})
+}
+
+func (h *TaskHandler) ResumeTask(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.services.GetAgentTaskManager().Resume(id, c.GetString(middleware.IdentityKey)); err != nil {
+		switch {
+		case errors.Is(err, agenttaskmanager.ErrTaskNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, agenttaskmanager.ErrTaskNotResumable):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, agenttaskmanager.ErrShuttingDown):
+			c.JSON(503, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{"task_status": agenttaskmanager.TaskStatusQueued, "task_id": id})
+}
+
+// ReplayTask queues a new task with the inputs of a dead-lettered task
+func (h *TaskHandler) ReplayTask(c *gin.Context) {
+	type params struct {
+		// RefreshCache scrapes or crawls again instead of using the cached pages
+		RefreshCache   bool           `json:"refreshCache"`
+		Params         map[string]any `json:"params"`
+		TimeoutSeconds int            `json:"timeoutSeconds" binding:"omitempty,min=1"`
+		CallbackURL    string         `json:"callbackUrl" binding:"omitempty,url"`
+	}
+
+	// The body is optional
+	var p params
+	if err := c.ShouldBindJSON(&p); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := agenttaskmanager.ReplayOptions{
+		Params:      p.Params,
+		Timeout:     time.Duration(p.TimeoutSeconds) * time.Second,
+		CallbackURL: p.CallbackURL,
+		RequestedBy: c.GetString(middleware.IdentityKey),
+	}
+	if p.RefreshCache {
+		if options.Params == nil {
+			options.Params = make(map[string]any)
+		}
+		options.Params["refreshCache"] = true
+	}
+
+	id := c.Param("id")
+	manager := h.services.GetAgentTaskManager()
+	replayID, err := manager.Replay(id, options)
+	if err != nil {
+		switch {
+		case errors.Is(err, agenttaskmanager.ErrTaskNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, agenttaskmanager.ErrTaskNotDeadLettered):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			respondQueueError(c, err)
+		}
+		return
+	}
+
+	status, _ := manager.GetTaskStatus(replayID)
+	c.JSON(200, gin.H{"task_status": status, "task_id": replayID, "replay_of": id})
+}
+
+// TaskHistory returns the audit log of a task's status changes, oldest first
+func (h *TaskHandler) TaskHistory(c *gin.Context) {
+	id := c.Param("id")
+	history, ok := h.services.GetAgentTaskManager().History(id)
+	if !ok {
+		c.JSON(404, gin.H{"error": "task not found"})
+		return
+	}
+	if history == nil {
+		history = []agenttaskmanager.Transition{}
+	}
+	c.JSON(200, gin.H{"task_id": id, "history": history})
+}
+
+// StreamTaskEvents sends the task's status changes, progress and agent events as Server-Sent Events
+// until the task finishes or the client disconnects
+func (h *TaskHandler) StreamTaskEvents(c *gin.Context) {
+	id := c.Param("id")
+	manager := h.services.GetAgentTaskManager()
+
+	// Subscribe before reading the task so no transition is missed
+	events, unsubscribe := manager.Subscribe(id)
+	defer unsubscribe()
+
+	task, ok := manager.GetTask(id)
+	if !ok {
+		c.JSON(404, gin.H{"error": "task not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent(agenttaskmanager.TaskEventStatus, agenttaskmanager.TaskEvent{
+		Type:     agenttaskmanager.TaskEventStatus,
+		TaskID:   id,
+		Status:   task.Status,
+		Progress: task.Progress,
+	})
+	if task.IsFinished() {
+		return
+	}
+
+	// Keeps proxies from closing an idle connection
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	// Tasks run by a worker in another process only publish their events there, their status is read
+	// from the store instead
+	poll := time.NewTicker(statusPollInterval)
+	defer poll.Stop()
+
+	status := task.Status
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			if event.Type != agenttaskmanager.TaskEventStatus {
+				return true
+			}
+			status = event.Status
+			// Stop once the task finishes
+			return !status.IsFinished()
+		case <-poll.C:
+			task, ok := manager.GetTask(id)
+			if !ok || task.Status == status {
+				return ok
+			}
+			status = task.Status
+			c.SSEvent(agenttaskmanager.TaskEventStatus, agenttaskmanager.TaskEvent{
+				Type:     agenttaskmanager.TaskEventStatus,
+				TaskID:   id,
+				Status:   task.Status,
+				Progress: task.Progress,
+			})
+			return !status.IsFinished()
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/gin-gonic/gin"
+)
+
+// pageTask is a convert-page task that is never run
+type pageTask struct {
+	id string
+}
+
+func (t *pageTask) Execute(ctx context.Context, reporter agenttask.Reporter) error {
+	return nil
+}
+
+func (t *pageTask) ID() string {
+	return t.id
+}
+
+func (t *pageTask) Type() string {
+	return agenttask.TaskTypeConvertPage
+}
+
+func (t *pageTask) Params() any {
+	return map[string]string{"url": "https://example.org/" + t.id}
+}
+
+// listTasks responds to a task listing with filter and decodes the tasks
+func listTasks(t *testing.T, manager *agenttaskmanager.AgentTaskManager, filter agenttaskmanager.TaskFilter, tasks any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	respondTaskPage(c, manager, filter)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	response := struct {
+		Tasks any `json:"tasks"`
+	}{Tasks: tasks}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListTasksQueuePositions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Not started, so the tasks stay queued
+	manager := agenttaskmanager.New(agenttaskmanager.Config{}, agenttaskmanager.NewMemoryTaskStore(), agenttaskmanager.NewMemoryQueue(0), nil, nil)
+	for _, id := range []string{"first", "second"} {
+		if _, err := manager.QueueTask(&pageTask{id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tasks []struct {
+		TaskID        string `json:"task_id"`
+		QueuePosition *int   `json:"queue_position"`
+	}
+	listTasks(t, manager, agenttaskmanager.TaskFilter{}, &tasks)
+
+	positions := make(map[string]int)
+	for _, task := range tasks {
+		if task.QueuePosition == nil {
+			t.Fatalf("%s has no queue position", task.TaskID)
+		}
+		positions[task.TaskID] = *task.QueuePosition
+	}
+	if positions["first"] != 1 || positions["second"] != 2 {
+		t.Errorf("got positions %v, want first 1 and second 2", positions)
+	}
+}
+
+func TestListDeadTasksShowsParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := agenttaskmanager.NewMemoryTaskStore()
+	manager := agenttaskmanager.New(agenttaskmanager.Config{}, store, agenttaskmanager.NewMemoryQueue(0), nil, nil)
+	deadLetteredAt := time.Now()
+	err := store.Save(agenttaskmanager.TaskRecord{
+		ID:             "dead",
+		Type:           "convert-page",
+		Params:         json.RawMessage(`{"url":"https://example.org","pageId":"page"}`),
+		Status:         agenttaskmanager.TaskStatusFailed,
+		CreatedAt:      deadLetteredAt,
+		FinishedAt:     &deadLetteredAt,
+		DeadLetteredAt: &deadLetteredAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []struct {
+		TaskID string            `json:"task_id"`
+		Params map[string]string `json:"params"`
+	}
+	listTasks(t, manager, agenttaskmanager.TaskFilter{DeadLettered: true}, &tasks)
+
+	if len(tasks) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(tasks))
+	}
+	if params := tasks[0].Params; params["url"] != "https://example.org" || params["pageId"] != "page" {
+		t.Errorf("got params %v, want the original inputs of the task", params)
+	}
+}
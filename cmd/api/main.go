@@ -70,6 +70,13 @@ func main() {
 	stop()
 	log.Println("shutting down gracefully, press Ctrl+C again to force")
 
+	// New tasks are rejected while running tasks finish, the server keeps answering status requests
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Tasks.DrainTimeout)
+	defer cancelDrain()
+	if err := services.Drain(drainCtx); err != nil {
+		log.Println("Interrupted running tasks, they run again on the next start:", err)
+	}
+
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -78,5 +85,9 @@ func main() {
 		log.Fatal("Server forced to shutdown: ", err)
 	}
 
+	if err := services.Close(); err != nil {
+		log.Println("Error closing services:", err)
+	}
+
 	log.Println("Server stopped")
 }
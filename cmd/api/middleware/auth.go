@@ -1,21 +1,34 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"log"
 
 	"github.com/ForTheChurch/buildforthechurch/cmd/api/config"
 	"github.com/gin-gonic/gin"
 )
 
+// IdentityKey is the context key of the name of the API key a request was made with
+const IdentityKey = "identity"
+
 func Auth(cfg config.Config) gin.HandlerFunc {
 	if cfg.AgentAPIKey == "" {
 		log.Fatal("AGENT_API_KEY is not set")
 	}
+
+	keys := map[string]string{"default": cfg.AgentAPIKey}
+	for name, key := range cfg.AgentAPIKeys {
+		keys[name] = key
+	}
+
 	return func(c *gin.Context) {
 		token := c.GetHeader("X-Agent-API-Key")
-		if token == cfg.AgentAPIKey {
-			c.Next()
-			return
+		for name, key := range keys {
+			if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+				c.Set(IdentityKey, name)
+				c.Next()
+				return
+			}
 		}
 
 		c.JSON(401, gin.H{"error": "Unauthorized"})
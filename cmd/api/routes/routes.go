@@ -11,19 +11,40 @@ func SetupRoutes(r gin.IRouter, services *services.Services) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	taskHandler := handlers.NewTaskHandler(services)
+	taskGroup := r.Group("/tasks")
+
+	taskGroup.GET("", taskHandler.ListTasks)
+	taskGroup.GET("/dead", taskHandler.ListDeadTasks)
+	taskGroup.GET("/:id", taskHandler.GetTask)
+	taskGroup.DELETE("/:id", taskHandler.CancelTask)
+	taskGroup.POST("/:id/resume", taskHandler.ResumeTask)
+	taskGroup.POST("/:id/replay", taskHandler.ReplayTask)
+	taskGroup.GET("/:id/history", taskHandler.TaskHistory)
+	taskGroup.GET("/:id/events", taskHandler.StreamTaskEvents)
+
+	scheduleHandler := handlers.NewScheduleHandler(services)
+	scheduleGroup := r.Group("/schedules")
+
+	scheduleGroup.GET("", scheduleHandler.ListSchedules)
+	scheduleGroup.POST("", scheduleHandler.CreateSchedule)
+	scheduleGroup.GET("/:id", scheduleHandler.GetSchedule)
+	scheduleGroup.PUT("/:id", scheduleHandler.UpdateSchedule)
+	scheduleGroup.DELETE("/:id", scheduleHandler.DeleteSchedule)
+
 	pageHandler := handlers.NewPageHandler(services)
 	pageGroup := r.Group("/pages")
 
 	pageGroup.POST("/convert-single-page", pageHandler.ConvertSinglePage)
 	// probably not at the right level, but that's ok for now
 	pageGroup.POST("/convert-whole-site", pageHandler.ConvertWholeSite)
-	// TODO dedupe this endpoint
-	pageGroup.GET("/task/:id", pageHandler.GetTaskStatus)
+	// Alias of GET /tasks/:id
+	pageGroup.GET("/task/:id", taskHandler.GetTask)
 
 	postHandler := handlers.NewPostHandler(services)
 	postGroup := r.Group("/posts")
 
 	postGroup.POST("/apply-youtube-transcript", postHandler.ApplyYoutubeTranscript)
-	// TODO dedupe this endpoint
-	postGroup.GET("/task/:id", pageHandler.GetTaskStatus)
+	// Alias of GET /tasks/:id
+	postGroup.GET("/task/:id", taskHandler.GetTask)
 }
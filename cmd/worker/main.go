@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/ForTheChurch/buildforthechurch/internal/worker"
+	"github.com/caarlos0/env/v11"
+	"github.com/joho/godotenv"
+)
+
+// worker runs the tasks queued by the API in a separate process, so conversions can be scaled
+// without scaling the HTTP server. It needs the durable queue, shared through the task store file.
+func main() {
+	// Create context that listens for the interrupt signal from the OS.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := godotenv.Load()
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Println("no .env file found, skipping")
+		} else {
+			log.Fatal(err)
+		}
+	}
+
+	var cfg worker.Config
+	if err := env.Parse(&cfg); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Tasks.Queue != agenttaskmanager.QueueBolt {
+		log.Fatal("the worker needs AGENT_TASK_QUEUE=bolt to share tasks with the API")
+	}
+	if cfg.Tasks.Parallelism <= 0 {
+		log.Fatal("AGENT_TASK_PARALLELISM must be at least 1")
+	}
+
+	services, err := worker.New(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Worker started with", cfg.Tasks.Parallelism, "workers")
+
+	// Wait for the interrupt signal
+	<-ctx.Done()
+
+	// Restore default behavior on the interrupt signal and notify user of shutdown.
+	stop()
+	log.Println("shutting down gracefully, press Ctrl+C again to force")
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Tasks.DrainTimeout)
+	defer cancelDrain()
+	if err := services.Drain(drainCtx); err != nil {
+		log.Println("Interrupted running tasks, they are queued again:", err)
+	}
+
+	if err := services.Close(); err != nil {
+		log.Println("Error closing services:", err)
+	}
+
+	log.Println("Worker stopped")
+}
@@ -1,11 +1,16 @@
 package agentmanager
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
 )
@@ -19,78 +24,778 @@ const (
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusCancelled TaskStatus = "cancelled"
+	// TaskStatusCompletedWithErrors is a task that finished although some of its children failed
+	TaskStatusCompletedWithErrors TaskStatus = "completed_with_errors"
 )
 
+// How long an idempotency key refers to the task it was first used with
+const idempotencyKeyTTL = 24 * time.Hour
+
+// QueueFullError is returned by QueueTask when the queue has no room for the task
+type QueueFullError struct {
+	// RetryAfter estimates when there will be room again
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return "task queue is full"
+}
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskFinished = errors.New("task already finished")
+	// ErrShuttingDown is returned for new tasks once Drain was called
+	ErrShuttingDown     = errors.New("application is shutting down")
+	ErrTaskNotResumable = errors.New("only failed, cancelled or partly failed tasks can be resumed")
+
+	// errTaskCancelled is the cause of a task context cancelled through Cancel
+	errTaskCancelled = errors.New("task cancelled")
+	// cancellationPollInterval is how often workers look for tasks cancelled by other processes
+	cancellationPollInterval = 2 * time.Second
+
+	// errTaskInterrupted is the cause of a task context cancelled because Drain ran out of time
+	errTaskInterrupted = errors.New("task interrupted by shutdown")
+)
+
+// IsFinished reports whether the status is terminal
+func (s TaskStatus) IsFinished() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusCancelled ||
+		s == TaskStatusCompletedWithErrors
+}
+
+// RestoreFunc rebuilds a persisted task so it can be queued again
+type RestoreFunc func(id string, taskType string, params json.RawMessage) (agenttask.AgentTask, error)
+
 type AgentTaskManager struct {
-	taskQueue   chan agenttask.AgentTask
+	queue       QueueBackend
 	finished    atomic.Bool
 	parallelism int
 
-	// TODO Use a persistent store for task status
-	taskStatus map[string]TaskStatus
-	mu         sync.RWMutex
+	// stopWorkers stops the workers from picking up tasks, workers waits for them to exit
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+
+	// serializes QueueTask so the queue can't grow past maxQueuedTasks
+	submitMu       sync.Mutex
+	maxQueuedTasks int
+	// serializes Replay so a task is replayed only once
+	replayMu sync.Mutex
+
+	timeouts            map[string]time.Duration
+	maxTimeout          time.Duration
+	maxParallelChildren int
+
+	// running tasks by task id
+	running   map[string]*runningTask
+	runningMu sync.Mutex
+	durations *durationTracker
+
+	store   TaskStore
+	restore RestoreFunc
+	mu      sync.Mutex
+
+	retryPolicies map[string]RetryPolicy
+	priorities    map[string]int
+
+	// the share of failed children that fails a parent task, see Config
+	childFailureThreshold float64
+	// how long finished tasks are kept, see Config
+	retention time.Duration
+
+	events    *eventBus
+	callbacks *CallbackNotifier
 }
 
-func New() *AgentTaskManager {
+type runningTask struct {
+	cancel    context.CancelCauseFunc
+	taskType  string
+	startedAt time.Time
+}
+
+// New creates a task manager. Callback URLs are ignored if callbacks is nil.
+//
+// Tasks are queued in queue. With a durable queue, workers in other processes sharing the store and
+// queue (see cmd/worker) run the tasks too, and the manager may run without workers of its own.
+func New(cfg Config, store TaskStore, queue QueueBackend, restore RestoreFunc, callbacks *CallbackNotifier) *AgentTaskManager {
 	am := &AgentTaskManager{
-		taskQueue:   make(chan agenttask.AgentTask, 64),
-		taskStatus:  make(map[string]TaskStatus),
-		parallelism: 4,
+		queue:         queue,
+		parallelism:   cfg.Parallelism,
+		running:       make(map[string]*runningTask),
+		durations:     newDurationTracker(),
+		store:         store,
+		restore:       restore,
+		retryPolicies: make(map[string]RetryPolicy),
+		priorities:    make(map[string]int),
+		events:        newEventBus(),
+		callbacks:     callbacks,
+
+		childFailureThreshold: cfg.ChildFailureThreshold,
+		retention:             cfg.Retention,
+
+		maxQueuedTasks:      cfg.MaxQueuedTasks,
+		timeouts:            make(map[string]time.Duration),
+		maxTimeout:          cfg.MaxTimeout,
+		maxParallelChildren: cfg.MaxParallelChildren,
+	}
+	// Nothing would run the tasks of an in-memory queue without workers
+	if am.parallelism < 0 || (am.parallelism == 0 && !queue.Durable()) {
+		am.parallelism = 1
+	}
+	for taskType, timeout := range defaultTimeouts {
+		am.timeouts[taskType] = timeout
+	}
+	for taskType, timeout := range cfg.Timeouts {
+		am.timeouts[taskType] = timeout
+	}
+	for taskType, policy := range defaultRetryPolicies {
+		am.retryPolicies[taskType] = policy
+	}
+	for taskType, priority := range defaultPriorities {
+		am.priorities[taskType] = priority
+	}
+	for taskType, priority := range cfg.Priorities {
+		am.priorities[taskType] = priority
 	}
 
 	am.finished.Store(false)
 	return am
 }
 
-func (a *AgentTaskManager) QueueTask(task agenttask.AgentTask) (string, error) {
+// SetRetryPolicy overrides the retry policy for a task type. Must be called before Start.
+func (a *AgentTaskManager) SetRetryPolicy(taskType string, policy RetryPolicy) {
+	a.retryPolicies[taskType] = policy
+}
+
+func (a *AgentTaskManager) retryPolicy(taskType string) RetryPolicy {
+	if policy, ok := a.retryPolicies[taskType]; ok {
+		return policy
+	}
+	return defaultRetryPolicy
+}
+
+// QueueTask queues a task and returns its id.
+//
+// If a task with the same idempotency key was queued recently, or an identical task (same type and params)
+// is still queued or running, the id of that task is returned instead and nothing is queued.
+func (a *AgentTaskManager) QueueTask(task agenttask.AgentTask, opts ...QueueOption) (string, error) {
 	if a.finished.Load() {
-		return "", errors.New("application is shutting down")
+		return "", ErrShuttingDown
 	}
 
-	a.mu.Lock()
-	a.taskStatus[task.ID()] = TaskStatusQueued
-	a.mu.Unlock()
+	params, err := json.Marshal(task.Params())
+	if err != nil {
+		return "", fmt.Errorf("error marshalling task params: %w", err)
+	}
+
+	a.submitMu.Lock()
+	defer a.submitMu.Unlock()
 
-	a.taskQueue <- task
+	var options TaskRecord
+	for _, opt := range opts {
+		opt(&options)
+	}
+	existingID, err := a.findDuplicate(task.Type(), params, options.IdempotencyKey)
+	if err != nil {
+		return "", err
+	}
+	if existingID != "" {
+		log.Println("Task is a duplicate of", existingID)
+		return existingID, nil
+	}
+
+	// Children don't count, a few site conversions would otherwise fill the queue with their pages.
+	// Tasks waiting to be retried do.
+	if a.maxQueuedTasks > 0 && countTopLevel(a.queue.Queued()) >= a.maxQueuedTasks {
+		return "", &QueueFullError{RetryAfter: a.retryAfter()}
+	}
+
+	record := TaskRecord{
+		ID:          task.ID(),
+		Type:        task.Type(),
+		Params:      params,
+		Status:      TaskStatusQueued,
+		Priority:    a.priority(task.Type()),
+		MaxAttempts: a.retryPolicy(task.Type()).MaxAttempts,
+		CreatedAt:   time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&record)
+	}
+	record.Timeout = a.boundTimeout(record.Type, record.Timeout)
+	record.MaxParallelChildren = a.boundParallelChildren(record.MaxParallelChildren)
+	record.History = []Transition{{To: TaskStatusQueued, At: record.CreatedAt, Actor: record.RequestedBy}}
+
+	if err := a.store.Save(record); err != nil {
+		return "", fmt.Errorf("error saving task: %w", err)
+	}
+
+	if err := a.push(task, record, time.Time{}); err != nil {
+		return "", err
+	}
 	return task.ID(), nil
 }
 
+// findDuplicate returns the id of a task that a new task should be deduplicated against, or ""
+func (a *AgentTaskManager) findDuplicate(taskType string, params json.RawMessage, idempotencyKey string) (string, error) {
+	records, err := a.store.List()
+	if err != nil {
+		return "", fmt.Errorf("error listing tasks: %w", err)
+	}
+
+	// A key always refers to the same task, even when the request body differs
+	if idempotencyKey != "" {
+		for _, record := range records {
+			if record.IdempotencyKey == idempotencyKey && time.Since(record.CreatedAt) < idempotencyKeyTTL {
+				return record.ID, nil
+			}
+		}
+		return "", nil
+	}
+
+	for _, record := range records {
+		if !record.IsFinished() && record.Type == taskType && bytes.Equal(record.Params, params) {
+			return record.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// Cancel stops a task and its children. Queued tasks are removed from the queue and running tasks have
+// their context cancelled. actor is recorded in the task history.
+func (a *AgentTaskManager) Cancel(id string, actor string) error {
+	record, ok := a.GetTask(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if record.IsFinished() {
+		return ErrTaskFinished
+	}
+
+	a.cancel(id, actor)
+	a.cancelChildren(id)
+	return nil
+}
+
+func (a *AgentTaskManager) cancel(id string, actor string) {
+	record, finished := a.cancelRecord(id, actor)
+
+	// The parent is checked once runningMu is released, if it fails because of this cancel its other
+	// children are cancelled too
+	if finished && record.ParentID != "" {
+		a.checkChildren(record.ParentID)
+	}
+}
+
+// cancelRecord cancels the task in this process, or asks the process running it to. It reports
+// whether the task finished.
+func (a *AgentTaskManager) cancelRecord(id string, actor string) (TaskRecord, bool) {
+	// Holding runningMu keeps a worker from starting the task in the meantime
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+
+	if running, isRunning := a.running[id]; isRunning {
+		// The worker records the cancelled status once Execute returns
+		log.Println("Cancelling running task", id)
+		record, _ := a.saveTask(id, func(r *TaskRecord) {
+			r.CancelledBy = actor
+		})
+		running.cancel(errTaskCancelled)
+		return record, false
+	}
+
+	// Running in another process, its worker cancels it once it notices, see watchCancellations
+	if record, _ := a.GetTask(id); record.Status == TaskStatusRunning && !record.AwaitingChildren && a.queue.Durable() {
+		log.Println("Requesting cancellation of task", id)
+		return a.saveTask(id, func(r *TaskRecord) {
+			r.CancelRequested = true
+			r.CancelledBy = actor
+		})
+	}
+
+	// In the queue, waiting to be retried or waiting for its children
+	log.Println("Cancelling task", id)
+	a.queue.Remove(id)
+	return a.saveTask(id, func(r *TaskRecord) {
+		finishedAt := time.Now()
+		r.Status = TaskStatusCancelled
+		r.CancelledBy = actor
+		r.NextAttemptAt = nil
+		r.AwaitingChildren = false
+		r.FinishedAt = &finishedAt
+	})
+}
+
+// Resume queues a failed, cancelled or partly failed task again. Work saved in its checkpoints,
+// like the pages of a whole site conversion that were already created or converted, is not done again.
+// actor is recorded in the task history.
+func (a *AgentTaskManager) Resume(id string, actor string) error {
+	if a.finished.Load() {
+		return ErrShuttingDown
+	}
+
+	record, ok := a.GetTask(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if !record.IsFinished() || record.Status == TaskStatusCompleted {
+		return ErrTaskNotResumable
+	}
+
+	task, err := a.restore(record.ID, record.Type, record.Params)
+	if err != nil {
+		return fmt.Errorf("error restoring task: %w", err)
+	}
+
+	a.updateTask(id, func(r *TaskRecord) {
+		r.Status = TaskStatusQueued
+		r.actor = actor
+		r.Attempts = 0
+		r.StartedAt = nil
+		r.FinishedAt = nil
+		r.NextAttemptAt = nil
+		r.AwaitingChildren = false
+		r.DeadLetteredAt = nil
+		// A cancellation requested while it ran in another process must not cancel the resumed task
+		r.CancelRequested = false
+		r.CancelledBy = ""
+		r.setError(nil)
+	})
+	return a.push(task, record, time.Time{})
+}
+
+// push puts a task in the queue, held back until notBefore if it is set. Children of the same parent
+// are limited by the MaxParallelChildren of the parent.
+func (a *AgentTaskManager) push(task agenttask.AgentTask, record TaskRecord, notBefore time.Time) error {
+	entry := QueueEntry{
+		TaskID:    task.ID(),
+		TaskType:  task.Type(),
+		Priority:  record.Priority,
+		NotBefore: notBefore,
+		Group:     record.ParentID,
+		task:      task,
+	}
+	if record.ParentID != "" {
+		parent, _ := a.GetTask(record.ParentID)
+		entry.GroupLimit = parent.MaxParallelChildren
+	}
+	if err := a.queue.Push(entry); err != nil {
+		return fmt.Errorf("error queueing task: %w", err)
+	}
+	return nil
+}
+
 func (a *AgentTaskManager) GetTaskStatus(id string) (TaskStatus, bool) {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	status, ok := a.taskStatus[id]
-	return status, ok
+	record, ok := a.GetTask(id)
+	return record.Status, ok
+}
+
+// Subscribe streams the status, progress and agent events of a task.
+// Call unsubscribe once done reading.
+func (a *AgentTaskManager) Subscribe(id string) (events <-chan TaskEvent, unsubscribe func()) {
+	return a.events.subscribe(id)
+}
+
+// GetTask returns the full record of a task
+func (a *AgentTaskManager) GetTask(id string) (TaskRecord, bool) {
+	record, ok, err := a.store.Get(id)
+	if err != nil {
+		log.Println("Error getting task:", err)
+		return TaskRecord{}, false
+	}
+	return record, ok
 }
 
+// Start starts the workers. They stop picking up tasks once ctx is done, but running tasks are only
+// stopped by Drain.
 func (a *AgentTaskManager) Start(ctx context.Context) {
+	ctx, a.stopWorkers = context.WithCancel(ctx)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	a.workers.Add(a.parallelism)
 	for i := 0; i < a.parallelism; i++ {
-		go a.run(ctx)
+		go func() {
+			defer a.workers.Done()
+			a.run(ctx, fmt.Sprintf("%s/%d", hostname, i))
+		}()
 	}
+
+	if a.retention > 0 {
+		go a.pruneFinished(ctx)
+	}
+
+	if a.queue.Durable() {
+		// Queued tasks are still in the queue, and tasks of workers that stopped are handed out again
+		a.checkAwaitingParents()
+		if a.parallelism > 0 {
+			go a.watchCancellations(ctx)
+		}
+		return
+	}
+	a.requeueUnfinished()
 }
 
-func (a *AgentTaskManager) run(ctx context.Context) {
+// checkAwaitingParents finishes the parents whose children finished while no process was running
+func (a *AgentTaskManager) checkAwaitingParents() {
+	records, err := a.store.List()
+	if err != nil {
+		log.Println("Error listing tasks:", err)
+		return
+	}
+	for _, record := range records {
+		if !record.IsFinished() && record.AwaitingChildren {
+			a.checkChildren(record.ID)
+		}
+	}
+}
+
+// watchCancellations cancels the tasks running in this process that were cancelled by another process
+// sharing the queue, see CancelRequested
+func (a *AgentTaskManager) watchCancellations(ctx context.Context) {
+	ticker := time.NewTicker(cancellationPollInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case task := <-a.taskQueue:
-			a.mu.Lock()
-			a.taskStatus[task.ID()] = TaskStatusRunning
-			a.mu.Unlock()
-
-			if err := task.Execute(ctx); err != nil {
-				// TODO retry task
-				a.mu.Lock()
-				a.taskStatus[task.ID()] = TaskStatusFailed
-				a.mu.Unlock()
-
-				log.Println("Error executing task:", err)
-			} else {
-				a.mu.Lock()
-				a.taskStatus[task.ID()] = TaskStatusCompleted
-				a.mu.Unlock()
-			}
+		case <-ticker.C:
 		case <-ctx.Done():
+			return
+		}
+
+		a.runningMu.Lock()
+		ids := make([]string, 0, len(a.running))
+		for id := range a.running {
+			ids = append(ids, id)
+		}
+		a.runningMu.Unlock()
+
+		for _, id := range ids {
+			if record, _ := a.GetTask(id); !record.CancelRequested {
+				continue
+			}
+			a.runningMu.Lock()
+			if running, ok := a.running[id]; ok {
+				log.Println("Cancelling running task", id)
+				running.cancel(errTaskCancelled)
+			}
+			a.runningMu.Unlock()
+		}
+	}
+}
+
+// requeueUnfinished queues the tasks that were queued or running when the process last stopped
+func (a *AgentTaskManager) requeueUnfinished() {
+	records, err := a.store.List()
+	if err != nil {
+		log.Println("Error listing tasks to requeue:", err)
+		return
+	}
+
+	var tasks []agenttask.AgentTask
+	var parents []string
+	for _, record := range records {
+		if record.IsFinished() {
+			continue
+		}
+
+		// Only waiting for children, which are requeued themselves
+		if record.AwaitingChildren {
+			parents = append(parents, record.ID)
+			continue
+		}
+
+		task, err := a.restore(record.ID, record.Type, record.Params)
+		if err != nil {
+			log.Println("Error restoring task", record.ID+":", err)
+			a.updateTask(record.ID, func(r *TaskRecord) {
+				finishedAt := time.Now()
+				r.Status = TaskStatusFailed
+				r.setError(err)
+				r.FinishedAt = &finishedAt
+			})
+			continue
+		}
+
+		// Running tasks start over from the beginning
+		a.updateTask(record.ID, func(r *TaskRecord) {
+			r.Status = TaskStatusQueued
+			r.actor = actorRestart
+			r.StartedAt = nil
+			r.NextAttemptAt = nil
+		})
+		tasks = append(tasks, task)
+	}
+
+	// Children may have finished right before the process stopped
+	for _, parentID := range parents {
+		a.checkChildren(parentID)
+	}
+
+	if len(tasks) == 0 {
+		return
+	}
+	log.Println("Requeueing", len(tasks), "unfinished tasks")
+
+	for _, task := range tasks {
+		record, _ := a.GetTask(task.ID())
+		if err := a.push(task, record, time.Time{}); err != nil {
+			log.Println("Error requeueing task", task.ID()+":", err)
+		}
+	}
+}
+
+func (a *AgentTaskManager) run(ctx context.Context, workerID string) {
+	for {
+		entry, ok := a.queue.Pop(ctx)
+		if !ok {
 			a.finished.Store(true)
 			return
 		}
+		task, ok := a.claim(entry)
+		if !ok {
+			a.queue.Done(entry.TaskID)
+			continue
+		}
+
+		// Running tasks outlive ctx so they can finish while draining
+		taskCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+		a.runningMu.Lock()
+		// Skip tasks that were cancelled after being queued
+		if status, _ := a.GetTaskStatus(task.ID()); status != TaskStatusQueued {
+			a.runningMu.Unlock()
+			a.queue.Done(task.ID())
+			cancel(nil)
+			continue
+		}
+		startedAt := time.Now()
+		a.running[task.ID()] = &runningTask{cancel: cancel, taskType: task.Type(), startedAt: startedAt}
+		a.runningMu.Unlock()
+
+		var attempts int
+		timeout := a.timeout(task.Type())
+		a.updateTask(task.ID(), func(r *TaskRecord) {
+			r.Status = TaskStatusRunning
+			r.WorkerID = workerID
+			r.StartedAt = &startedAt
+			r.NextAttemptAt = nil
+			r.Progress = nil
+			r.Attempts++
+			attempts = r.Attempts
+			if r.Timeout > 0 {
+				timeout = r.Timeout
+			}
+		})
+
+		timeoutCtx, cancelTimeout := context.WithTimeout(taskCtx, timeout)
+		err := task.Execute(timeoutCtx, &taskReporter{manager: a, taskID: task.ID()})
+		cancelTimeout()
+
+		a.runningMu.Lock()
+		delete(a.running, task.ID())
+		a.runningMu.Unlock()
+		a.queue.Done(task.ID())
+		a.durations.record(task.Type(), time.Since(startedAt))
+		cancelled := errors.Is(context.Cause(taskCtx), errTaskCancelled)
+		interrupted := errors.Is(context.Cause(taskCtx), errTaskInterrupted)
+		cancel(nil)
+
+		switch {
+		case interrupted:
+			// Picked up again from a durable queue, or from the store on the next start
+			log.Println("Task interrupted by shutdown:", task.ID())
+			record, _ := a.saveTask(task.ID(), func(r *TaskRecord) {
+				r.Status = TaskStatusQueued
+				r.actor = actorShutdown
+				r.StartedAt = nil
+				r.Progress = nil
+				// The attempt didn't get the chance to finish
+				r.Attempts--
+			})
+			if err := a.push(task, record, time.Time{}); err != nil {
+				log.Println("Error requeueing task", task.ID()+":", err)
+			}
+		case cancelled:
+			log.Println("Task cancelled:", task.ID())
+			a.updateTask(task.ID(), func(r *TaskRecord) {
+				finishedAt := time.Now()
+				r.setError(nil)
+				r.Status = TaskStatusCancelled
+				r.FinishedAt = &finishedAt
+			})
+			a.cancelChildren(task.ID())
+		case err != nil:
+			log.Println("Error executing task:", err)
+
+			// Children keep running while waiting for the retry, it picks them up from the checkpoints
+			policy := a.retryPolicy(task.Type())
+			if attempts < policy.MaxAttempts && agenttask.IsRetryable(err) {
+				a.retryTask(task, policy.Backoff(attempts), err)
+				continue
+			}
+
+			a.cancelChildren(task.ID())
+
+			a.updateTask(task.ID(), func(r *TaskRecord) {
+				finishedAt := time.Now()
+				r.Status = TaskStatusFailed
+				r.setError(err)
+				r.FinishedAt = &finishedAt
+			})
+		default:
+			if record, _ := a.GetTask(task.ID()); len(record.ChildIDs) > 0 {
+				a.awaitChildren(task.ID())
+				continue
+			}
+			a.updateTask(task.ID(), func(r *TaskRecord) {
+				finishedAt := time.Now()
+				r.Status = TaskStatusCompleted
+				r.setError(nil)
+				r.FinishedAt = &finishedAt
+			})
+		}
+	}
+}
+
+// claim returns the task of a popped queue entry, ok is false if it should not run
+func (a *AgentTaskManager) claim(entry QueueEntry) (agenttask.AgentTask, bool) {
+	record, ok := a.GetTask(entry.TaskID)
+	if !ok {
+		log.Println("Task not found:", entry.TaskID)
+		return nil, false
+	}
+
+	// The worker that was running it stopped. It starts over like after a restart, unless it was
+	// being cancelled.
+	if entry.Reclaimed && record.Status == TaskStatusRunning {
+		record, _ = a.saveTask(record.ID, func(r *TaskRecord) {
+			if r.CancelRequested {
+				finishedAt := time.Now()
+				r.Status = TaskStatusCancelled
+				r.FinishedAt = &finishedAt
+				return
+			}
+			r.Status = TaskStatusQueued
+			r.actor = actorRestart
+			r.StartedAt = nil
+		})
+		if record.Status == TaskStatusCancelled {
+			a.cancelChildren(record.ID)
+		}
+	}
+	if record.Status != TaskStatusQueued {
+		return nil, false
+	}
+
+	if entry.task != nil {
+		return entry.task, true
+	}
+	task, err := a.restore(record.ID, record.Type, record.Params)
+	if err != nil {
+		log.Println("Error restoring task", record.ID+":", err)
+		a.updateTask(record.ID, func(r *TaskRecord) {
+			finishedAt := time.Now()
+			r.Status = TaskStatusFailed
+			r.setError(err)
+			r.FinishedAt = &finishedAt
+		})
+		return nil, false
+	}
+	return task, true
+}
+
+// retryTask puts a failed task back in the queue, held back until the backoff has passed
+func (a *AgentTaskManager) retryTask(task agenttask.AgentTask, backoff time.Duration, err error) {
+	log.Println("Retrying task", task.ID(), "in", backoff)
+
+	nextAttemptAt := time.Now().Add(backoff)
+	record, _ := a.saveTask(task.ID(), func(r *TaskRecord) {
+		r.Status = TaskStatusQueued
+		r.setError(err)
+		r.NextAttemptAt = &nextAttemptAt
+	})
+	if err := a.push(task, record, nextAttemptAt); err != nil {
+		log.Println("Error requeueing task", task.ID()+":", err)
+	}
+}
+
+// updateTask applies fn to the stored record of a task
+func (a *AgentTaskManager) updateTask(id string, fn func(r *TaskRecord)) {
+	record, finished := a.saveTask(id, fn)
+
+	// The parent may have been waiting for this task
+	if finished && record.ParentID != "" {
+		a.checkChildren(record.ParentID)
+	}
+}
+
+// saveTask applies fn to the stored record of a task and reports whether the task just finished
+func (a *AgentTaskManager) saveTask(id string, fn func(r *TaskRecord)) (TaskRecord, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var previousStatus TaskStatus
+	record, ok, err := a.store.Update(id, func(record *TaskRecord) {
+		previousStatus = record.Status
+		fn(record)
+
+		if record.Status != previousStatus {
+			now := time.Now()
+			record.recordTransition(previousStatus, now)
+
+			// Tasks only fail once retries are exhausted or won't help
+			if record.Status == TaskStatusFailed {
+				record.DeadLetteredAt = &now
+			}
+		}
+	})
+	if err != nil {
+		log.Println("Error saving task", id+":", err)
+		return TaskRecord{}, false
 	}
+	if !ok {
+		log.Println("Task not found:", id)
+		return TaskRecord{}, false
+	}
+
+	if record.Status == previousStatus {
+		return record, false
+	}
+
+	a.events.publish(TaskEvent{Type: TaskEventStatus, TaskID: id, Status: record.Status})
+	if record.IsFinished() {
+		a.notifyCallback(record)
+		return record, true
+	}
+	return record, false
+}
+
+// notifyCallback posts the result of a finished task to its callback URL in the background
+func (a *AgentTaskManager) notifyCallback(record TaskRecord) {
+	if record.CallbackURL == "" || a.callbacks == nil {
+		return
+	}
+
+	payload := CallbackPayload{
+		TaskID:     record.ID,
+		TaskType:   record.Type,
+		Status:     record.Status,
+		Error:      record.Error,
+		ErrorCode:  record.ErrorCode,
+		Documents:  record.Documents,
+		Result:     record.Result(),
+		Children:   record.Children,
+		FinishedAt: record.FinishedAt,
+	}
+	if payload.Documents == nil {
+		payload.Documents = []agenttask.Document{}
+	}
+
+	go a.callbacks.deliver(record.CallbackURL, payload, func(delivery CallbackDelivery) {
+		a.updateTask(record.ID, func(r *TaskRecord) {
+			r.CallbackDeliveries = append(r.CallbackDeliveries, delivery)
+		})
+	})
 }
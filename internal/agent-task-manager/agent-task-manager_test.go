@@ -0,0 +1,32 @@
+package agentmanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueueTaskLimit(t *testing.T) {
+	a := New(Config{MaxQueuedTasks: 2}, NewMemoryTaskStore(), NewMemoryQueue(0), restoreFake, nil)
+	if _, err := a.QueueTask(&fakeTask{id: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	// Waiting to be retried still takes a place in the queue
+	push(t, a.queue, QueueEntry{TaskID: "retry", NotBefore: time.Now().Add(time.Minute)})
+	// Children don't
+	push(t, a.queue, QueueEntry{TaskID: "child", Group: "queued"})
+
+	_, err := a.QueueTask(&fakeTask{id: "rejected"})
+	var queueFull *QueueFullError
+	if !errors.As(err, &queueFull) {
+		t.Fatalf("got %v, want a QueueFullError", err)
+	}
+	if queueFull.RetryAfter <= 0 {
+		t.Errorf("got Retry-After %s, want a positive duration", queueFull.RetryAfter)
+	}
+
+	a.queue.Remove("retry")
+	if _, err := a.QueueTask(&fakeTask{id: "accepted"}); err != nil {
+		t.Fatal(err)
+	}
+}
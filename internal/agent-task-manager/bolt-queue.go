@@ -0,0 +1,347 @@
+package agentmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// queueBucket holds the entries waiting for a worker
+	queueBucket = []byte("queue")
+	// inflightBucket holds the popped entries until Done
+	inflightBucket = []byte("inflight")
+)
+
+const (
+	// queueLease is how long a popped entry belongs to its process without being renewed. Entries of a
+	// process that stopped renewing, e.g. because it crashed, are handed out again.
+	queueLease = time.Minute
+	// queuePollInterval is how often Pop looks for entries pushed by other processes
+	queuePollInterval = time.Second
+)
+
+// inflightEntry is a popped entry and the process that has it
+type inflightEntry struct {
+	Entry      QueueEntry `json:"entry"`
+	Owner      string     `json:"owner"`
+	LeaseUntil time.Time  `json:"lease_until"`
+}
+
+// boltQueue is a durable QueueBackend in a shared bolt file, so tasks pushed by one process can
+// be popped by workers in another
+type boltQueue struct {
+	db    *boltDB
+	order queueOrder
+	// owner identifies this process in the leases of popped entries
+	owner string
+
+	// ready has a value when this process pushed or released entries
+	ready chan struct{}
+
+	// held are the entries popped by this process, their leases are renewed until Done
+	held   map[string]bool
+	heldMu sync.Mutex
+	stop   chan struct{}
+}
+
+var _ QueueBackend = &boltQueue{}
+
+func newBoltQueue(db *boltDB, aging time.Duration) *boltQueue {
+	q := &boltQueue{
+		db:    db,
+		order: queueOrder{aging: aging},
+		owner: uuid.New().String(),
+		ready: make(chan struct{}, 1),
+		held:  make(map[string]bool),
+		stop:  make(chan struct{}),
+	}
+	go q.renewLeases()
+	return q
+}
+
+func (q *boltQueue) Push(entry QueueEntry) error {
+	entry.QueuedAt = time.Now()
+	err := q.db.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(queueBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.Seq = seq
+		return putJSON(bucket, entry.TaskID, entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	q.signal()
+	return nil
+}
+
+func (q *boltQueue) Pop(ctx context.Context) (QueueEntry, bool) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		entry, ok, err := q.pop()
+		if err != nil {
+			log.Println("Error popping from the task queue:", err)
+		}
+		if ok {
+			return entry, true
+		}
+
+		select {
+		case <-q.ready:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return QueueEntry{}, false
+		}
+	}
+}
+
+// pop moves the next entry to the in-flight entries of this process, if there is one
+func (q *boltQueue) pop() (QueueEntry, bool, error) {
+	// Most polls find nothing to claim, so look in a read-only transaction first, which doesn't
+	// write the file
+	var claimable bool
+	err := q.db.view(func(tx *bolt.Tx) error {
+		now := time.Now()
+		entries, running, expired, err := readQueue(tx, now)
+		if err != nil {
+			return err
+		}
+		claimable = len(expired) > 0 || q.order.next(entries, running, now) >= 0
+		return nil
+	})
+	if err != nil || !claimable {
+		return QueueEntry{}, false, err
+	}
+
+	var popped QueueEntry
+	var ok bool
+	err = q.db.update(func(tx *bolt.Tx) error {
+		queue, inflight := tx.Bucket(queueBucket), tx.Bucket(inflightBucket)
+		now := time.Now()
+		entries, running, expired, err := readQueue(tx, now)
+		if err != nil {
+			return err
+		}
+
+		// Entries of processes that stopped renewing their leases are queued again
+		for _, held := range expired {
+			held.Entry.Reclaimed = true
+			if err := inflight.Delete([]byte(held.Entry.TaskID)); err != nil {
+				return err
+			}
+			if err := putJSON(queue, held.Entry.TaskID, held.Entry); err != nil {
+				return err
+			}
+			entries = append(entries, held.Entry)
+		}
+
+		next := q.order.next(entries, running, now)
+		if next < 0 {
+			return nil
+		}
+		popped, ok = entries[next], true
+		if err := queue.Delete([]byte(popped.TaskID)); err != nil {
+			return err
+		}
+		return putJSON(inflight, popped.TaskID, inflightEntry{
+			Entry:      popped,
+			Owner:      q.owner,
+			LeaseUntil: now.Add(queueLease),
+		})
+	})
+	if err != nil || !ok {
+		return QueueEntry{}, false, err
+	}
+
+	q.heldMu.Lock()
+	q.held[popped.TaskID] = true
+	q.heldMu.Unlock()
+	return popped, true, nil
+}
+
+// readQueue returns the queued entries, how many in-flight entries of each group hold a slot, and
+// the in-flight entries whose lease expired
+func readQueue(tx *bolt.Tx, now time.Time) (entries []QueueEntry, running map[string]int, expired []inflightEntry, err error) {
+	running = make(map[string]int)
+	err = tx.Bucket(inflightBucket).ForEach(func(_, data []byte) error {
+		var held inflightEntry
+		if err := json.Unmarshal(data, &held); err != nil {
+			return err
+		}
+		if now.After(held.LeaseUntil) {
+			expired = append(expired, held)
+		} else if held.Entry.Group != "" {
+			running[held.Entry.Group]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = tx.Bucket(queueBucket).ForEach(func(_, data []byte) error {
+		var entry QueueEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, running, expired, err
+}
+
+func (q *boltQueue) Done(id string) {
+	q.heldMu.Lock()
+	delete(q.held, id)
+	q.heldMu.Unlock()
+
+	err := q.db.update(func(tx *bolt.Tx) error {
+		inflight := tx.Bucket(inflightBucket)
+		data := inflight.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		var held inflightEntry
+		if err := json.Unmarshal(data, &held); err != nil {
+			return err
+		}
+		// Reclaimed by another process in the meantime
+		if held.Owner != q.owner {
+			return nil
+		}
+		return inflight.Delete([]byte(id))
+	})
+	if err != nil {
+		log.Println("Error releasing task", id, "from the queue:", err)
+	}
+
+	// Tasks of the group may be waiting for the slot
+	q.signal()
+}
+
+func (q *boltQueue) Remove(id string) bool {
+	var removed bool
+	err := q.db.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(queueBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return nil
+		}
+		removed = true
+		return bucket.Delete([]byte(id))
+	})
+	if err != nil {
+		log.Println("Error removing task", id, "from the queue:", err)
+		return false
+	}
+	return removed
+}
+
+func (q *boltQueue) Snapshot() []QueueEntry {
+	return q.order.sorted(q.Queued(), time.Now())
+}
+
+func (q *boltQueue) Queued() []QueueEntry {
+	var entries []QueueEntry
+	err := q.db.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).ForEach(func(_, data []byte) error {
+			var entry QueueEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Println("Error listing the task queue:", err)
+	}
+	return entries
+}
+
+func (q *boltQueue) Durable() bool {
+	return true
+}
+
+// Close stops renewing the leases of the entries this process still holds
+func (q *boltQueue) Close() error {
+	close(q.stop)
+	return nil
+}
+
+// renewLeases keeps the entries popped by this process from being handed out again
+func (q *boltQueue) renewLeases() {
+	ticker := time.NewTicker(queueLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.stop:
+			return
+		}
+
+		q.heldMu.Lock()
+		ids := make([]string, 0, len(q.held))
+		for id := range q.held {
+			ids = append(ids, id)
+		}
+		q.heldMu.Unlock()
+		if len(ids) == 0 {
+			continue
+		}
+
+		leaseUntil := time.Now().Add(queueLease)
+		err := q.db.update(func(tx *bolt.Tx) error {
+			inflight := tx.Bucket(inflightBucket)
+			for _, id := range ids {
+				data := inflight.Get([]byte(id))
+				if data == nil {
+					continue
+				}
+				var held inflightEntry
+				if err := json.Unmarshal(data, &held); err != nil {
+					return err
+				}
+				// Reclaimed by another process in the meantime
+				if held.Owner != q.owner {
+					continue
+				}
+				held.LeaseUntil = leaseUntil
+				if err := putJSON(inflight, id, held); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Println("Error renewing task leases:", err)
+		}
+	}
+}
+
+func (q *boltQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func putJSON(bucket *bolt.Bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s: %w", key, err)
+	}
+	return bucket.Put([]byte(key), data)
+}
@@ -0,0 +1,114 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openSharedQueue opens the queue of the store file at path like a separate process would
+func openSharedQueue(t *testing.T, path string) *boltQueue {
+	t.Helper()
+	store, queue, err := NewSharedBoltStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		queue.Close()
+		store.Close()
+	})
+	return queue.(*boltQueue)
+}
+
+// inflight returns the in-flight entry of a task, ok is false if there is none
+func inflight(t *testing.T, queue *boltQueue, id string) (held inflightEntry, ok bool) {
+	t.Helper()
+	err := queue.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(inflightBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &held)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return held, ok
+}
+
+func TestBoltQueueSharedBetweenProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	api, worker := openSharedQueue(t, path), openSharedQueue(t, path)
+
+	push(t, api, QueueEntry{TaskID: "task", TaskType: "convert-page"})
+	entry := pop(t, worker, 3*time.Second)
+	if entry.TaskID != "task" || entry.TaskType != "convert-page" {
+		t.Fatalf("popped %+v", entry)
+	}
+	if held, ok := inflight(t, api, "task"); !ok || held.Owner != worker.owner {
+		t.Fatalf("in-flight entry %+v, want one owned by the worker", held)
+	}
+
+	worker.Done("task")
+	if _, ok := inflight(t, api, "task"); ok {
+		t.Fatal("entry still in flight after Done")
+	}
+}
+
+func TestBoltQueueReclaimsExpiredLeases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	crashed, worker := openSharedQueue(t, path), openSharedQueue(t, path)
+
+	push(t, crashed, QueueEntry{TaskID: "task"})
+	pop(t, crashed, time.Second)
+
+	// The process stopped renewing its lease
+	held, _ := inflight(t, crashed, "task")
+	held.LeaseUntil = time.Now().Add(-time.Second)
+	err := crashed.db.update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(inflightBucket), "task", held)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := pop(t, worker, 3*time.Second)
+	if entry.TaskID != "task" || !entry.Reclaimed {
+		t.Fatalf("popped %+v, want the reclaimed task", entry)
+	}
+
+	// The late Done of the first process doesn't release the entry of the worker
+	crashed.Done("task")
+	if held, ok := inflight(t, worker, "task"); !ok || held.Owner != worker.owner {
+		t.Fatalf("in-flight entry %+v, want one owned by the worker", held)
+	}
+	worker.Done("task")
+	if _, ok := inflight(t, worker, "task"); ok {
+		t.Fatal("entry still in flight after Done")
+	}
+}
+
+func TestBoltQueueIdlePollsDontWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	worker := openSharedQueue(t, path)
+	// Not due yet, so there's nothing to claim
+	push(t, worker, QueueEntry{TaskID: "retry", NotBefore: time.Now().Add(time.Hour)})
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	popNothing(t, worker, 2500*time.Millisecond)
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Fatal("polling an idle queue wrote the file")
+	}
+}
@@ -0,0 +1,259 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	tasksBucket     = []byte("tasks")
+	schedulesBucket = []byte("schedules")
+)
+
+type boltTaskStore struct {
+	db *boltDB
+}
+
+var _ TaskStore = &boltTaskStore{}
+
+// NewBoltTaskStore opens (or creates) a task store in a single file on disk
+func NewBoltTaskStore(path string) (TaskStore, error) {
+	db, err := openBoltDB(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return &boltTaskStore{db: db}, nil
+}
+
+// NewSharedBoltStore opens (or creates) a task store and a durable queue in a single file on disk that
+// other processes, like cmd/worker, can open at the same time
+func NewSharedBoltStore(path string, aging time.Duration) (TaskStore, QueueBackend, error) {
+	db, err := openBoltDB(path, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &boltTaskStore{db: db}, newBoltQueue(db, aging), nil
+}
+
+func (s *boltTaskStore) Get(id string) (TaskRecord, bool, error) {
+	var record TaskRecord
+	var ok bool
+	err := s.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(tasksBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return TaskRecord{}, false, fmt.Errorf("error getting task %s: %w", id, err)
+	}
+	return record, ok, nil
+}
+
+func (s *boltTaskStore) Save(record TaskRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshalling task %s: %w", record.ID, err)
+	}
+	return s.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).Put([]byte(record.ID), data)
+	})
+}
+
+func (s *boltTaskStore) Update(id string, fn func(r *TaskRecord)) (TaskRecord, bool, error) {
+	var record TaskRecord
+	var ok bool
+	err := s.db.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tasksBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		fn(&record)
+		return putJSON(bucket, id, record)
+	})
+	if err != nil {
+		return TaskRecord{}, false, fmt.Errorf("error updating task %s: %w", id, err)
+	}
+	return record, ok, nil
+}
+
+func (s *boltTaskStore) List() ([]TaskRecord, error) {
+	var records []TaskRecord
+	err := s.db.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(_, data []byte) error {
+			var record TaskRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tasks: %w", err)
+	}
+	return records, nil
+}
+
+func (s *boltTaskStore) Delete(ids ...string) error {
+	return s.db.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tasksBucket)
+		for _, id := range ids {
+			if err := bucket.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltTaskStore) GetSchedule(id string) (Schedule, bool, error) {
+	var schedule Schedule
+	var ok bool
+	err := s.db.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(schedulesBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &schedule)
+	})
+	if err != nil {
+		return Schedule{}, false, fmt.Errorf("error getting schedule %s: %w", id, err)
+	}
+	return schedule, ok, nil
+}
+
+func (s *boltTaskStore) SaveSchedule(schedule Schedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("error marshalling schedule %s: %w", schedule.ID, err)
+	}
+	return s.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).Put([]byte(schedule.ID), data)
+	})
+}
+
+func (s *boltTaskStore) DeleteSchedule(id string) error {
+	return s.db.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).Delete([]byte(id))
+	})
+}
+
+func (s *boltTaskStore) ListSchedules() ([]Schedule, error) {
+	var schedules []Schedule
+	err := s.db.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(_, data []byte) error {
+			var schedule Schedule
+			if err := json.Unmarshal(data, &schedule); err != nil {
+				return err
+			}
+			schedules = append(schedules, schedule)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (s *boltTaskStore) Close() error {
+	return s.db.close()
+}
+
+// boltDB is a bolt file, either held open by this process or, when shared, opened for each
+// transaction so processes can take turns
+type boltDB struct {
+	path   string
+	shared bool
+	// db is nil when shared
+	db *bolt.DB
+	// serializes the transactions of this process on a shared file
+	mu sync.Mutex
+}
+
+func openBoltDB(path string, shared bool) (*boltDB, error) {
+	b := &boltDB{path: path, shared: shared}
+	db, err := b.open(false)
+	if err != nil {
+		return nil, fmt.Errorf("error opening task store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{tasksBucket, schedulesBucket, queueBucket, inflightBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating buckets: %w", err)
+	}
+
+	if shared {
+		return b, db.Close()
+	}
+	b.db = db
+	return b, nil
+}
+
+func (b *boltDB) open(readOnly bool) (*bolt.DB, error) {
+	// Don't wait forever if another process holds the lock on the file.
+	// Shared files are only locked for a transaction, so waiting longer is fine.
+	timeout := 5 * time.Second
+	if b.shared {
+		timeout = 30 * time.Second
+	}
+	return bolt.Open(b.path, 0600, &bolt.Options{Timeout: timeout, ReadOnly: readOnly})
+}
+
+func (b *boltDB) view(fn func(tx *bolt.Tx) error) error {
+	if !b.shared {
+		return b.db.View(fn)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	db, err := b.open(true)
+	if err != nil {
+		return fmt.Errorf("error opening task store: %w", err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (b *boltDB) update(fn func(tx *bolt.Tx) error) error {
+	if !b.shared {
+		return b.db.Update(fn)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	db, err := b.open(false)
+	if err != nil {
+		return fmt.Errorf("error opening task store: %w", err)
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (b *boltDB) close() error {
+	if b.shared {
+		return nil
+	}
+	return b.db.Close()
+}
@@ -0,0 +1,126 @@
+package agentmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+const (
+	callbackMaxAttempts    = 5
+	callbackInitialBackoff = 2 * time.Second
+	callbackTimeout        = 10 * time.Second
+)
+
+// CallbackPayload is the JSON body posted to a task's callback URL when it finishes
+type CallbackPayload struct {
+	TaskID     string               `json:"task_id"`
+	TaskType   string               `json:"task_type"`
+	Status     TaskStatus           `json:"task_status"`
+	Error      string               `json:"error,omitempty"`
+	ErrorCode  string               `json:"error_code,omitempty"`
+	Documents  []agenttask.Document `json:"documents"`
+	Result     TaskResult           `json:"result"`
+	Children   []ChildOutcome       `json:"children,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+}
+
+// CallbackDelivery records one attempt at posting a callback
+type CallbackDelivery struct {
+	Attempt    int        `json:"attempt"`
+	Status     TaskStatus `json:"task_status"`
+	StatusCode int        `json:"status_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Delivered  bool       `json:"delivered"`
+	At         time.Time  `json:"at"`
+}
+
+// CallbackNotifier posts signed task results to callback URLs.
+//
+// Each request has an X-Agent-Timestamp header with the unix time and an X-Agent-Signature header
+// with the hex encoded HMAC-SHA256 of "<timestamp>.<body>", keyed with the secret.
+type CallbackNotifier struct {
+	secret string
+	client *http.Client
+}
+
+func NewCallbackNotifier(secret string, client *http.Client) *CallbackNotifier {
+	return &CallbackNotifier{secret: secret, client: client}
+}
+
+// Sign returns the signature of a callback body sent at timestamp
+func (n *CallbackNotifier) Sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(n.secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts the payload until it is accepted or the attempts run out, recording each attempt
+func (n *CallbackNotifier) deliver(url string, payload CallbackPayload, record func(delivery CallbackDelivery)) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("Error marshalling callback for task", payload.TaskID+":", err)
+		return
+	}
+
+	backoff := callbackInitialBackoff
+	for attempt := 1; attempt <= callbackMaxAttempts; attempt++ {
+		delivery := CallbackDelivery{Attempt: attempt, Status: payload.Status, At: time.Now()}
+
+		statusCode, err := n.post(url, body)
+		delivery.StatusCode = statusCode
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Delivered = true
+		}
+		record(delivery)
+
+		if delivery.Delivered {
+			return
+		}
+
+		log.Println("Error delivering callback for task", payload.TaskID+":", err)
+		if attempt < callbackMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func (n *CallbackNotifier) post(url string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Timestamp", timestamp)
+	req.Header.Set("X-Agent-Signature", n.Sign(timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
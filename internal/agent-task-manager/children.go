@@ -0,0 +1,266 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// spawnChild queues task as the child of the running task parentID for the checkpoint key.
+// Children skip the queue limit and deduplication, their parent was already accepted. They are
+// accepted while draining too and start on the next start.
+func (a *AgentTaskManager) spawnChild(parentID string, key string, task agenttask.AgentTask) error {
+	parent, ok := a.GetTask(parentID)
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	// Keep the child of an earlier attempt unless it failed or was cancelled
+	if previousID := parent.Checkpoints[key].ChildID; previousID != "" {
+		previous, ok := a.GetTask(previousID)
+		if ok && (!previous.IsFinished() || previous.Status == TaskStatusCompleted) {
+			return nil
+		}
+	}
+
+	params, err := json.Marshal(task.Params())
+	if err != nil {
+		return fmt.Errorf("error marshalling task params: %w", err)
+	}
+
+	// Children keep the priority of their parent so a bulk task doesn't jump the queue through them
+	record := TaskRecord{
+		ID:          task.ID(),
+		Type:        task.Type(),
+		Params:      params,
+		Status:      TaskStatusQueued,
+		Priority:    parent.Priority,
+		MaxAttempts: a.retryPolicy(task.Type()).MaxAttempts,
+		Timeout:     a.timeout(task.Type()),
+		CreatedAt:   time.Now(),
+		ParentID:    parentID,
+		RequestedBy: parent.RequestedBy,
+	}
+	record.History = []Transition{{To: TaskStatusQueued, At: record.CreatedAt, Actor: actorParent + parentID}}
+	if err := a.store.Save(record); err != nil {
+		return fmt.Errorf("error saving task: %w", err)
+	}
+
+	a.updateTask(parentID, func(r *TaskRecord) {
+		checkpoint := r.Checkpoints[key]
+		// The new child takes the place of the one it replaces
+		if i := slices.Index(r.ChildIDs, checkpoint.ChildID); checkpoint.ChildID != "" && i >= 0 {
+			r.ChildIDs[i] = task.ID()
+		} else {
+			r.ChildIDs = append(r.ChildIDs, task.ID())
+		}
+		checkpoint.ChildID = task.ID()
+		r.setCheckpoint(key, checkpoint)
+	})
+
+	return a.push(task, record, time.Time{})
+}
+
+// replaceChild puts the child replayID in the place of the child it replays, in the children and
+// checkpoints of the parent
+func (a *AgentTaskManager) replaceChild(parentID, childID, replayID string) {
+	a.updateTask(parentID, func(r *TaskRecord) {
+		if i := slices.Index(r.ChildIDs, childID); i >= 0 {
+			r.ChildIDs[i] = replayID
+		}
+		for key, checkpoint := range r.Checkpoints {
+			if checkpoint.ChildID == childID {
+				checkpoint.ChildID = replayID
+				r.setCheckpoint(key, checkpoint)
+			}
+		}
+	})
+	a.checkChildren(parentID)
+}
+
+// checkpoints returns the checkpoints of a task. A checkpoint is done once its child completed.
+func (a *AgentTaskManager) checkpoints(id string) map[string]agenttask.Checkpoint {
+	record, _ := a.GetTask(id)
+	checkpoints := make(map[string]agenttask.Checkpoint, len(record.Checkpoints))
+	for key, checkpoint := range record.Checkpoints {
+		var done bool
+		if checkpoint.ChildID != "" {
+			child, ok := a.GetTask(checkpoint.ChildID)
+			done = ok && child.Status == TaskStatusCompleted
+		}
+		checkpoints[key] = agenttask.Checkpoint{Value: checkpoint.Value, Done: done}
+	}
+	return checkpoints
+}
+
+// awaitChildren is called once the Execute of a parent task succeeded.
+// The parent stays running until checkChildren sees all of its children finished.
+func (a *AgentTaskManager) awaitChildren(parentID string) {
+	a.updateTask(parentID, func(r *TaskRecord) {
+		r.AwaitingChildren = true
+	})
+	a.checkChildren(parentID)
+}
+
+// ChildOutcome is how a child task ended, or its current status while it runs
+type ChildOutcome struct {
+	TaskID    string          `json:"task_id"`
+	TaskType  string          `json:"task_type"`
+	Params    json.RawMessage `json:"params"`
+	Status    TaskStatus      `json:"task_status"`
+	Error     string          `json:"error,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+}
+
+// checkChildren updates a parent task waiting for its children. Once all of them finished the parent
+// completes, with errors if some failed. If more fail than the failure threshold allows, the parent
+// fails right away and its remaining children are cancelled.
+//
+// A parent that already finished because of its children is updated too, when a failed child was
+// resumed or replayed and finished again.
+func (a *AgentTaskManager) checkChildren(parentID string) {
+	for {
+		parent, ok := a.GetTask(parentID)
+		if !ok || !tracksChildren(parent) {
+			return
+		}
+		settled := parent.IsFinished()
+
+		// The children are read before updating the parent, store calls can't be nested in an update
+		children := a.childOutcomes(parent.ChildIDs)
+
+		var applied, abort bool
+		var progress *agenttask.Progress
+		a.updateTask(parentID, func(r *TaskRecord) {
+			if !tracksChildren(*r) || r.IsFinished() != settled {
+				return
+			}
+			if settled {
+				// Keep a finished parent finished until its resumed children finish too
+				if slices.ContainsFunc(children, func(child ChildOutcome) bool { return !child.Status.IsFinished() }) {
+					r.Children = children
+					return
+				}
+				r.DeadLetteredAt = nil
+			}
+			applied = true
+			abort, progress = a.applyChildOutcomes(r, children)
+		})
+		if !applied {
+			return
+		}
+
+		a.events.publish(TaskEvent{Type: TaskEventProgress, TaskID: parentID, Progress: progress})
+		// The children of a parent that already finished were resumed on purpose
+		if abort && !settled {
+			a.cancelChildren(parentID)
+			return
+		}
+
+		// A child that finished while they were read may have checked the parent before this update
+		// overwrote it, so check again until the children stay the same
+		if slices.EqualFunc(children, a.childOutcomes(parent.ChildIDs), func(read, current ChildOutcome) bool {
+			return read.Status == current.Status
+		}) {
+			return
+		}
+	}
+}
+
+// tracksChildren reports whether checkChildren updates the task: it is waiting for its children, or
+// finished with errors because of them
+func tracksChildren(r TaskRecord) bool {
+	switch {
+	case r.Status == TaskStatusRunning:
+		return r.AwaitingChildren
+	case r.Status == TaskStatusCompletedWithErrors, r.Status == TaskStatusFailed:
+		return len(r.ChildIDs) > 0 && r.ErrorCode == agenttask.ErrorCodeChildrenFailed
+	}
+	return false
+}
+
+// childOutcomes returns the current outcome of each child task
+func (a *AgentTaskManager) childOutcomes(childIDs []string) []ChildOutcome {
+	children := make([]ChildOutcome, 0, len(childIDs))
+	for _, childID := range childIDs {
+		child, ok, err := a.store.Get(childID)
+		if err != nil || !ok {
+			// Missing children count as failed rather than keeping the parent running forever
+			log.Println("Error getting child task", childID+":", err)
+			child = TaskRecord{ID: childID, Status: TaskStatusFailed, Error: "task not found"}
+		}
+		children = append(children, ChildOutcome{
+			TaskID:    child.ID,
+			TaskType:  child.Type,
+			Params:    child.Params,
+			Status:    child.Status,
+			Error:     child.Error,
+			ErrorCode: child.ErrorCode,
+		})
+	}
+	return children
+}
+
+// applyChildOutcomes records the outcome of the children on their parent and finishes it if they all
+// finished or too many failed. abort reports whether the parent failed because of the threshold.
+func (a *AgentTaskManager) applyChildOutcomes(r *TaskRecord, children []ChildOutcome) (abort bool, progress *agenttask.Progress) {
+	var finished, failed int
+	for _, child := range children {
+		if child.Status.IsFinished() {
+			finished++
+			if child.Status != TaskStatusCompleted {
+				failed++
+			}
+		}
+	}
+
+	total := len(children)
+	r.Children = children
+	progress = &agenttask.Progress{Phase: agenttask.PhaseConverting, Done: finished, Total: total}
+	r.Progress = progress
+
+	threshold := a.childFailureThreshold
+	if r.FailureThreshold != nil {
+		threshold = *r.FailureThreshold
+	}
+	abort = failed > 0 && float64(failed) > threshold*float64(total)
+	if !abort && finished < total {
+		return false, progress
+	}
+
+	finishedAt := time.Now()
+	r.AwaitingChildren = false
+	r.FinishedAt = &finishedAt
+	switch {
+	case abort:
+		r.Status = TaskStatusFailed
+		r.setError(agenttask.WithCode(agenttask.ErrorCodeChildrenFailed,
+			fmt.Errorf("%d of %d child tasks failed, more than the failure threshold of %g%%", failed, total, threshold*100)))
+	case failed > 0:
+		r.Status = TaskStatusCompletedWithErrors
+		r.setError(agenttask.WithCode(agenttask.ErrorCodeChildrenFailed,
+			fmt.Errorf("%d of %d child tasks failed", failed, total)))
+	default:
+		r.Status = TaskStatusCompleted
+		r.setError(nil)
+	}
+	return abort, progress
+}
+
+// cancelChildren cancels the unfinished children of a task
+func (a *AgentTaskManager) cancelChildren(parentID string) {
+	parent, ok := a.GetTask(parentID)
+	if !ok {
+		return
+	}
+	for _, childID := range parent.ChildIDs {
+		if err := a.Cancel(childID, actorParent+parentID); err != nil && !errors.Is(err, ErrTaskFinished) {
+			log.Println("Error cancelling child task", childID+":", err)
+		}
+	}
+}
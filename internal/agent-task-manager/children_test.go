@@ -0,0 +1,249 @@
+package agentmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// fakeTask is a task that does nothing, the tests set the status of its record themselves
+type fakeTask struct {
+	id string
+}
+
+func (t *fakeTask) Execute(ctx context.Context, reporter agenttask.Reporter) error {
+	return nil
+}
+
+func (t *fakeTask) ID() string {
+	return t.id
+}
+
+func (t *fakeTask) Type() string {
+	return "fake"
+}
+
+func (t *fakeTask) Params() any {
+	return map[string]string{"id": t.id}
+}
+
+func restoreFake(id string, taskType string, params json.RawMessage) (agenttask.AgentTask, error) {
+	return &fakeTask{id: id}, nil
+}
+
+func newTestManager(store TaskStore, queue QueueBackend) *AgentTaskManager {
+	return New(Config{ChildFailureThreshold: 0.5}, store, queue, restoreFake, nil)
+}
+
+// saveFamily saves a parent waiting for children with the given statuses. Queued children are pushed.
+func saveFamily(t *testing.T, a *AgentTaskManager, threshold *float64, statuses ...TaskStatus) (parentID string, childIDs []string) {
+	t.Helper()
+	parentID = "parent"
+	for i := range statuses {
+		childIDs = append(childIDs, fmt.Sprintf("child-%d", i))
+	}
+	parent := TaskRecord{
+		ID:               parentID,
+		Type:             "fake",
+		Status:           TaskStatusRunning,
+		CreatedAt:        time.Now(),
+		ChildIDs:         childIDs,
+		AwaitingChildren: true,
+		FailureThreshold: threshold,
+	}
+	if err := a.store.Save(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, status := range statuses {
+		child := TaskRecord{ID: childIDs[i], Type: "fake", Status: status, CreatedAt: time.Now(), ParentID: parentID}
+		if status == TaskStatusFailed {
+			// Failed tasks go to the dead-letter list, see saveTask
+			child.DeadLetteredAt = &child.CreatedAt
+		}
+		if err := a.store.Save(child); err != nil {
+			t.Fatal(err)
+		}
+		if status == TaskStatusQueued {
+			if err := a.push(&fakeTask{id: child.ID}, child, time.Time{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return parentID, childIDs
+}
+
+func TestCheckChildren(t *testing.T) {
+	noFailures := 0.0
+	tests := []struct {
+		name      string
+		threshold *float64
+		children  []TaskStatus
+		want      TaskStatus
+		// wantChildren is the status of each child afterwards, the same as children if nil
+		wantChildren []TaskStatus
+	}{
+		{
+			name:     "all completed",
+			children: []TaskStatus{TaskStatusCompleted, TaskStatusCompleted, TaskStatusCompleted},
+			want:     TaskStatusCompleted,
+		},
+		{
+			name:     "some failed",
+			children: []TaskStatus{TaskStatusCompleted, TaskStatusFailed, TaskStatusCompleted},
+			want:     TaskStatusCompletedWithErrors,
+		},
+		{
+			name:     "some still queued",
+			children: []TaskStatus{TaskStatusFailed, TaskStatusQueued, TaskStatusQueued},
+			want:     TaskStatusRunning,
+		},
+		{
+			name:         "more failed than the threshold",
+			children:     []TaskStatus{TaskStatusFailed, TaskStatusCancelled, TaskStatusQueued},
+			want:         TaskStatusFailed,
+			wantChildren: []TaskStatus{TaskStatusFailed, TaskStatusCancelled, TaskStatusCancelled},
+		},
+		{
+			name:         "threshold of the task",
+			threshold:    &noFailures,
+			children:     []TaskStatus{TaskStatusFailed, TaskStatusQueued, TaskStatusCompleted},
+			want:         TaskStatusFailed,
+			wantChildren: []TaskStatus{TaskStatusFailed, TaskStatusCancelled, TaskStatusCompleted},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestManager(NewMemoryTaskStore(), NewMemoryQueue(0))
+			parentID, childIDs := saveFamily(t, a, test.threshold, test.children...)
+
+			a.checkChildren(parentID)
+
+			parent, _ := a.GetTask(parentID)
+			if parent.Status != test.want {
+				t.Fatalf("parent is %s, want %s", parent.Status, test.want)
+			}
+			if len(parent.Children) != len(childIDs) {
+				t.Fatalf("parent has %d child outcomes, want %d", len(parent.Children), len(childIDs))
+			}
+			wantChildren := test.wantChildren
+			if wantChildren == nil {
+				wantChildren = test.children
+			}
+			for i, childID := range childIDs {
+				if status, _ := a.GetTaskStatus(childID); status != wantChildren[i] {
+					t.Errorf("%s is %s, want %s", childID, status, wantChildren[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckChildrenOfFinishedParent(t *testing.T) {
+	tests := []struct {
+		name string
+		// retry queues the failed first child again and returns the id of the task that takes its place
+		retry func(t *testing.T, a *AgentTaskManager, childID string) string
+	}{
+		{
+			name: "resumed",
+			retry: func(t *testing.T, a *AgentTaskManager, childID string) string {
+				if err := a.Resume(childID, "test"); err != nil {
+					t.Fatal(err)
+				}
+				return childID
+			},
+		},
+		{
+			name: "replayed",
+			retry: func(t *testing.T, a *AgentTaskManager, childID string) string {
+				replayID, err := a.Replay(childID, ReplayOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return replayID
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newTestManager(NewMemoryTaskStore(), NewMemoryQueue(0))
+			parentID, childIDs := saveFamily(t, a, nil, TaskStatusFailed, TaskStatusCompleted, TaskStatusCompleted)
+			a.checkChildren(parentID)
+			if status, _ := a.GetTaskStatus(parentID); status != TaskStatusCompletedWithErrors {
+				t.Fatalf("parent is %s, want %s", status, TaskStatusCompletedWithErrors)
+			}
+
+			retryID := test.retry(t, a, childIDs[0])
+			parent, _ := a.GetTask(parentID)
+			if parent.Status != TaskStatusCompletedWithErrors || parent.ChildIDs[0] != retryID {
+				t.Fatalf("parent is %s with children %v, want %s with %s first", parent.Status, parent.ChildIDs,
+					TaskStatusCompletedWithErrors, retryID)
+			}
+
+			a.updateTask(retryID, func(r *TaskRecord) {
+				r.Status = TaskStatusCompleted
+			})
+
+			parent, _ = a.GetTask(parentID)
+			if parent.Status != TaskStatusCompleted || parent.Error != "" {
+				t.Fatalf("parent is %s with error %q, want %s", parent.Status, parent.Error, TaskStatusCompleted)
+			}
+			for _, child := range parent.Children {
+				if child.Status != TaskStatusCompleted {
+					t.Errorf("outcome of %s is %s, want %s", child.TaskID, child.Status, TaskStatusCompleted)
+				}
+			}
+		})
+	}
+}
+
+// Cancelling the children of a parent on a shared bolt file fails the parent past the threshold, which
+// cancels its last child. It used to deadlock on the nested store calls and on runningMu.
+func TestCancelChildrenOnSharedBoltStore(t *testing.T) {
+	store, queue, err := NewSharedBoltStore(filepath.Join(t.TempDir(), "tasks.db"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	defer queue.Close()
+
+	a := newTestManager(store, queue)
+	parentID, childIDs := saveFamily(t, a, nil, TaskStatusQueued, TaskStatusQueued, TaskStatusQueued)
+
+	done := make(chan error)
+	go func() {
+		for _, childID := range childIDs[:2] {
+			if err := a.Cancel(childID, "test"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("cancelling the children deadlocked")
+	}
+
+	if status, _ := a.GetTaskStatus(parentID); status != TaskStatusFailed {
+		t.Fatalf("parent is %s, want %s", status, TaskStatusFailed)
+	}
+	if status, _ := a.GetTaskStatus(childIDs[2]); status != TaskStatusCancelled {
+		t.Fatalf("last child is %s, want %s", status, TaskStatusCancelled)
+	}
+	if snapshot := queue.Snapshot(); len(snapshot) != 0 {
+		t.Fatalf("queue has %d entries, want 0", len(snapshot))
+	}
+}
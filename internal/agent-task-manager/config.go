@@ -0,0 +1,43 @@
+package agentmanager
+
+import "time"
+
+type Config struct {
+	StorePath string `env:"AGENT_TASK_STORE_PATH" envDefault:".agent-tasks.db"`
+	// Queue is the queue backend, QueueMemory or QueueBolt. The bolt queue lives in the store file,
+	// which other processes like cmd/worker can then open too.
+	Queue string `env:"AGENT_TASK_QUEUE" envDefault:"memory"`
+
+	// Parallelism is how many tasks run at the same time in this process. It may be 0 with a durable
+	// queue, to leave running tasks to workers in other processes.
+	Parallelism int `env:"AGENT_TASK_PARALLELISM" envDefault:"4"`
+	// MaxQueuedTasks is how many tasks may wait in the queue before new ones are rejected. Child tasks,
+	// like the pages of a site conversion, don't count. Tasks waiting to be retried do.
+	MaxQueuedTasks int `env:"AGENT_TASK_MAX_QUEUED" envDefault:"64"`
+	// MaxParallelChildren is how many children of one task, e.g. the pages of a site, run at the same time.
+	// Requests can ask for up to Parallelism.
+	MaxParallelChildren int `env:"AGENT_TASK_MAX_PARALLEL_CHILDREN" envDefault:"4"`
+
+	// Timeouts overrides how long task types may run, e.g. "convert-site:15m,convert-page:10m"
+	Timeouts map[string]time.Duration `env:"AGENT_TASK_TIMEOUTS"`
+	// MaxTimeout bounds the timeout requests can ask for
+	MaxTimeout time.Duration `env:"AGENT_TASK_MAX_TIMEOUT" envDefault:"1h"`
+
+	// DrainTimeout is how long running tasks may take to finish on shutdown before they are
+	// interrupted and requeued
+	DrainTimeout time.Duration `env:"AGENT_TASK_DRAIN_TIMEOUT" envDefault:"2m"`
+
+	// Priorities overrides the priority of task types, e.g. "convert-site:5,convert-page:10"
+	Priorities map[string]int `env:"AGENT_TASK_PRIORITIES"`
+	// PriorityAging is how long a task waits to gain one priority point
+	PriorityAging time.Duration `env:"AGENT_TASK_PRIORITY_AGING" envDefault:"1m"`
+
+	// Retention is how long finished tasks are kept, 0 keeps them forever. Failed tasks in the
+	// dead-letter list are kept until they are resumed or replayed. The idempotency keys of deleted
+	// tasks are forgotten.
+	Retention time.Duration `env:"AGENT_TASK_RETENTION" envDefault:"168h"`
+
+	// ChildFailureThreshold is the share of child tasks, from 0 to 1, that may fail before their
+	// parent gives up and cancels the rest. 0 fails the parent on the first failed child.
+	ChildFailureThreshold float64 `env:"AGENT_TASK_CHILD_FAILURE_THRESHOLD" envDefault:"0.5"`
+}
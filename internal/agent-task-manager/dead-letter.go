@@ -0,0 +1,103 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTaskNotDeadLettered = errors.New("only failed tasks that were not replayed yet can be replayed")
+
+// ReplayOptions change a dead-lettered task when it is replayed. Zero values keep the original.
+type ReplayOptions struct {
+	// Params overrides top level parameters of the task, e.g. {"refreshCache": true}
+	Params map[string]any
+	// Timeout overrides how long the task may run, up to the server maximum
+	Timeout time.Duration
+	// CallbackURL overrides where the result is posted
+	CallbackURL string
+	// RequestedBy is the API key identity asking for the replay
+	RequestedBy string
+}
+
+// Replay queues a new task with the inputs of a dead-lettered task and returns its id.
+// The dead-lettered task leaves the dead-letter list and points to its replay in ReplayedBy.
+func (a *AgentTaskManager) Replay(id string, options ReplayOptions) (string, error) {
+	a.replayMu.Lock()
+	defer a.replayMu.Unlock()
+
+	record, ok := a.GetTask(id)
+	if !ok {
+		return "", ErrTaskNotFound
+	}
+	if !record.IsDeadLettered() {
+		return "", ErrTaskNotDeadLettered
+	}
+
+	params, err := overrideParams(record.Params, options.Params)
+	if err != nil {
+		return "", err
+	}
+
+	task, err := a.restore(uuid.New().String(), record.Type, params)
+	if err != nil {
+		return "", fmt.Errorf("error restoring task: %w", err)
+	}
+
+	timeout := record.Timeout
+	if options.Timeout > 0 {
+		timeout = options.Timeout
+	}
+	callbackURL := record.CallbackURL
+	if options.CallbackURL != "" {
+		callbackURL = options.CallbackURL
+	}
+
+	replayID, err := a.QueueTask(task,
+		WithCallbackURL(callbackURL),
+		WithTimeout(timeout),
+		WithFailureThreshold(record.FailureThreshold),
+		WithMaxParallelChildren(record.MaxParallelChildren),
+		WithRequestedBy(options.RequestedBy),
+		func(r *TaskRecord) {
+			r.ReplayOf = id
+			// The replay of a child counts towards the outcome of its parent
+			r.ParentID = record.ParentID
+		})
+	if err != nil {
+		return "", err
+	}
+
+	log.Println("Replaying task", id, "as", replayID)
+	a.updateTask(id, func(r *TaskRecord) {
+		r.ReplayedBy = replayID
+	})
+	if record.ParentID != "" {
+		a.replaceChild(record.ParentID, id, replayID)
+	}
+	return replayID, nil
+}
+
+// overrideParams replaces the top level fields of params found in overrides
+func overrideParams(params json.RawMessage, overrides map[string]any) (json.RawMessage, error) {
+	if len(overrides) == 0 {
+		return params, nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(params, &fields); err != nil {
+		return nil, fmt.Errorf("error unmarshalling task params: %w", err)
+	}
+	for key, value := range overrides {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling param %s: %w", key, err)
+		}
+		fields[key] = data
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,44 @@
+package agentmanager
+
+import (
+	"context"
+	"log"
+)
+
+// Drain shuts the manager down: new tasks are rejected with ErrShuttingDown and workers stop picking up
+// queued tasks, while running tasks get until ctx is done to finish. Tasks still running then are
+// interrupted and saved as queued, like the tasks left in the queue, so they run again on the next start.
+func (a *AgentTaskManager) Drain(ctx context.Context) error {
+	a.finished.Store(true)
+	if a.stopWorkers == nil {
+		return nil
+	}
+	a.stopWorkers()
+
+	stopped := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(stopped)
+	}()
+
+	a.runningMu.Lock()
+	log.Println("Draining", len(a.running), "running tasks")
+	a.runningMu.Unlock()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+
+	a.runningMu.Lock()
+	log.Println("Interrupting", len(a.running), "tasks that didn't finish in time")
+	for _, running := range a.running {
+		running.cancel(errTaskInterrupted)
+	}
+	a.runningMu.Unlock()
+
+	// Tasks return soon once their context is cancelled
+	<-stopped
+	return ctx.Err()
+}
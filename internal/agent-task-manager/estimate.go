@@ -0,0 +1,152 @@
+package agentmanager
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// How many recent runs per task type are averaged for estimates
+const recentDurationsPerType = 20
+
+// Used until a task type has finished at least once
+var defaultTaskDurations = map[string]time.Duration{
+	agenttask.TaskTypeConvertPage:       2 * time.Minute,
+	agenttask.TaskTypeConvertSite:       5 * time.Minute,
+	agenttask.TaskTypeYoutubeTranscript: 3 * time.Minute,
+}
+
+const defaultTaskDuration = 2 * time.Minute
+
+// durationTracker keeps the run times of recent tasks per type
+type durationTracker struct {
+	recent map[string][]time.Duration
+	mu     sync.Mutex
+}
+
+func newDurationTracker() *durationTracker {
+	return &durationTracker{recent: make(map[string][]time.Duration)}
+}
+
+func (d *durationTracker) record(taskType string, duration time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	recent := append(d.recent[taskType], duration)
+	if len(recent) > recentDurationsPerType {
+		recent = recent[len(recent)-recentDurationsPerType:]
+	}
+	d.recent[taskType] = recent
+}
+
+func (d *durationTracker) average(taskType string) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	recent := d.recent[taskType]
+	if len(recent) == 0 {
+		if duration, ok := defaultTaskDurations[taskType]; ok {
+			return duration
+		}
+		return defaultTaskDuration
+	}
+
+	var total time.Duration
+	for _, duration := range recent {
+		total += duration
+	}
+	return total / time.Duration(len(recent))
+}
+
+// QueueInfo is where a queued task stands
+type QueueInfo struct {
+	// Position is 1 for the next task to start
+	Position         int
+	EstimatedStartAt time.Time
+}
+
+// QueueInfos returns where each task in the queue stands, by task id. Tasks that aren't due yet, e.g.
+// waiting to be retried, aren't included. It reads the queue once, so call it once for a list of tasks.
+func (a *AgentTaskManager) QueueInfos() map[string]QueueInfo {
+	queued := a.queue.Snapshot()
+	starts := a.estimateStarts(queued)
+	infos := make(map[string]QueueInfo, len(queued))
+	for i, entry := range queued {
+		infos[entry.TaskID] = QueueInfo{Position: i + 1, EstimatedStartAt: starts[i]}
+	}
+	return infos
+}
+
+// estimateStarts estimates when each of the queued tasks will start, by handing them out in order
+// to the worker expected to free up first. Running and queued tasks are assumed to take the recent
+// average for their type.
+func (a *AgentTaskManager) estimateStarts(queued []QueueEntry) []time.Time {
+	now := time.Now()
+
+	// Workers in other processes aren't known, assume at least one
+	workers := max(a.parallelism, 1)
+	freeAt := make([]time.Time, 0, workers)
+	a.runningMu.Lock()
+	for _, running := range a.running {
+		freeAt = append(freeAt, later(now, running.startedAt.Add(a.durations.average(running.taskType))))
+	}
+	a.runningMu.Unlock()
+	for len(freeAt) < workers {
+		freeAt = append(freeAt, now)
+	}
+
+	starts := make([]time.Time, len(queued))
+	for i, entry := range queued {
+		next := 0
+		for j := range freeAt {
+			if freeAt[j].Before(freeAt[next]) {
+				next = j
+			}
+		}
+		starts[i] = freeAt[next]
+		freeAt[next] = freeAt[next].Add(a.durations.average(entry.TaskType))
+	}
+	return starts
+}
+
+// retryAfter estimates how long until the queue has room again
+func (a *AgentTaskManager) retryAfter() time.Duration {
+	queued := a.queue.Snapshot()
+	// A slot frees up once the first queued task that isn't a child starts
+	first := slices.IndexFunc(queued, func(entry QueueEntry) bool {
+		return entry.Group == ""
+	})
+	if first >= 0 {
+		wait := time.Until(a.estimateStarts(queued[:first+1])[first])
+		return max(wait, time.Second)
+	}
+
+	// Otherwise all of them are waiting to be retried
+	var wait time.Duration
+	for _, entry := range a.queue.Queued() {
+		if until := time.Until(entry.NotBefore); entry.Group == "" && until > 0 && (wait == 0 || until < wait) {
+			wait = until
+		}
+	}
+	return max(wait, time.Second)
+}
+
+// countTopLevel counts the entries of tasks that aren't children, only they count towards MaxQueuedTasks
+func countTopLevel(entries []QueueEntry) int {
+	var count int
+	for _, entry := range entries {
+		if entry.Group == "" {
+			count++
+		}
+	}
+	return count
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,69 @@
+package agentmanager
+
+import (
+	"sync"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// Task event types
+const (
+	TaskEventStatus   = "status"
+	TaskEventProgress = "progress"
+	TaskEventAgent    = "agent"
+)
+
+// TaskEvent is published to subscribers of a task
+type TaskEvent struct {
+	Type     string                `json:"type"`
+	TaskID   string                `json:"task_id"`
+	Status   TaskStatus            `json:"task_status,omitempty"`
+	Progress *agenttask.Progress   `json:"progress,omitempty"`
+	Agent    *agenttask.AgentEvent `json:"agent,omitempty"`
+}
+
+// eventBus fans out task events to subscribers
+type eventBus struct {
+	subscribers map[string]map[chan TaskEvent]struct{}
+	mu          sync.Mutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[string]map[chan TaskEvent]struct{})}
+}
+
+func (b *eventBus) subscribe(taskID string) (<-chan TaskEvent, func()) {
+	ch := make(chan TaskEvent, 64)
+
+	b.mu.Lock()
+	if b.subscribers[taskID] == nil {
+		b.subscribers[taskID] = make(map[chan TaskEvent]struct{})
+	}
+	b.subscribers[taskID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[taskID], ch)
+			if len(b.subscribers[taskID]) == 0 {
+				delete(b.subscribers, taskID)
+			}
+		})
+	}
+}
+
+func (b *eventBus) publish(event TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.TaskID] {
+		select {
+		case ch <- event:
+		default:
+			// Never block a task on a slow subscriber, it misses the event instead
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package agentmanager
+
+import "time"
+
+// Actors of transitions that no API caller asked for
+const (
+	actorRestart  = "restart"
+	actorShutdown = "shutdown"
+	actorParent   = "task:"
+)
+
+// Transition is an entry of the audit log of a task, one for every status change
+type Transition struct {
+	From TaskStatus `json:"from,omitempty"`
+	To   TaskStatus `json:"to"`
+	At   time.Time  `json:"at"`
+	// Attempt is the attempt the task was on, 0 before it first ran
+	Attempt int `json:"attempt"`
+	// WorkerID is set for transitions made by a worker, e.g. "hostname/2"
+	WorkerID string `json:"worker_id,omitempty"`
+	// Actor is who caused the transition: the API key identity of a request, "task:<id>" for
+	// a parent task, "schedule:<id>" for a schedule, "restart" or "shutdown"
+	Actor     string `json:"actor,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// recordTransition appends the change from the previous status to the history of the task
+func (r *TaskRecord) recordTransition(from TaskStatus, at time.Time) {
+	transition := Transition{
+		From:      from,
+		To:        r.Status,
+		At:        at,
+		Attempt:   r.Attempts,
+		Actor:     r.actor,
+		Error:     r.Error,
+		ErrorCode: r.ErrorCode,
+	}
+	if from == TaskStatusRunning || r.Status == TaskStatusRunning {
+		transition.WorkerID = r.WorkerID
+	}
+	if r.Status == TaskStatusCancelled && transition.Actor == "" {
+		transition.Actor = r.CancelledBy
+	}
+	r.History = append(r.History, transition)
+	r.actor = ""
+}
+
+// History returns the audit log of a task, oldest first
+func (a *AgentTaskManager) History(id string) ([]Transition, bool) {
+	record, ok := a.GetTask(id)
+	if !ok {
+		return nil, false
+	}
+	return record.History, true
+}
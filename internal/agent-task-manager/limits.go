@@ -0,0 +1,49 @@
+package agentmanager
+
+import (
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// How long each task type may run by default
+var defaultTimeouts = map[string]time.Duration{
+	agenttask.TaskTypeConvertPage: 5 * time.Minute,
+	// Only crawls the site and creates the pages, each page is converted by a child task
+	agenttask.TaskTypeConvertSite: 5 * time.Minute,
+	// Transcripts can be long
+	agenttask.TaskTypeYoutubeTranscript: 8 * time.Minute,
+}
+
+// defaultTimeout is used for task types without a timeout
+const defaultTimeout = 5 * time.Minute
+
+func (a *AgentTaskManager) timeout(taskType string) time.Duration {
+	if timeout, ok := a.timeouts[taskType]; ok {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// boundTimeout returns the timeout requested for a task, within the server maximum
+func (a *AgentTaskManager) boundTimeout(taskType string, requested time.Duration) time.Duration {
+	if requested <= 0 {
+		return a.timeout(taskType)
+	}
+	if a.maxTimeout > 0 {
+		return min(requested, a.maxTimeout)
+	}
+	return requested
+}
+
+// boundParallelChildren returns how many children of a task may run at once, within the number of workers.
+// Workers of other processes aren't known, so the bound is only applied if this process has workers.
+func (a *AgentTaskManager) boundParallelChildren(requested int) int {
+	if requested <= 0 {
+		requested = a.maxParallelChildren
+	}
+	if a.parallelism > 0 {
+		return min(requested, a.parallelism)
+	}
+	return requested
+}
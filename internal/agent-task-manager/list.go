@@ -0,0 +1,126 @@
+package agentmanager
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TaskFilter selects the tasks returned by ListTasks. Zero values match everything.
+type TaskFilter struct {
+	Status TaskStatus
+	Type   string
+	// ParentID only matches the children of this task
+	ParentID string
+	// DeadLettered only matches failed tasks in the dead-letter list, see DeadLetteredAt
+	DeadLettered bool
+	// Since only matches tasks created at or after this time
+	Since time.Time
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// TaskPage is one page of tasks, newest first
+type TaskPage struct {
+	Tasks []TaskRecord
+	// NextCursor is empty on the last page
+	NextCursor string
+}
+
+// ListTasks returns the tasks matching filter, newest first
+func (a *AgentTaskManager) ListTasks(filter TaskFilter) (TaskPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+
+	var after *TaskRecord
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		after = &TaskRecord{ID: id, CreatedAt: createdAt}
+	}
+
+	records, err := a.store.List()
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	var tasks []TaskRecord
+	for _, record := range records {
+		if filter.Status != "" && record.Status != filter.Status {
+			continue
+		}
+		if filter.Type != "" && record.Type != filter.Type {
+			continue
+		}
+		if filter.ParentID != "" && record.ParentID != filter.ParentID {
+			continue
+		}
+		if filter.DeadLettered && !record.IsDeadLettered() {
+			continue
+		}
+		if !filter.Since.IsZero() && record.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		if after != nil && compareNewestFirst(record, *after) <= 0 {
+			continue
+		}
+		tasks = append(tasks, record)
+	}
+	slices.SortFunc(tasks, compareNewestFirst)
+
+	page := TaskPage{Tasks: tasks}
+	if len(tasks) > limit {
+		page.Tasks = tasks[:limit]
+		last := page.Tasks[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	if page.Tasks == nil {
+		page.Tasks = []TaskRecord{}
+	}
+	return page, nil
+}
+
+// compareNewestFirst orders records by creation time descending, ties are broken by id
+func compareNewestFirst(a, b TaskRecord) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(b.ID, a.ID)
+}
+
+func encodeCursor(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	nanos, id, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	return time.Unix(0, n), id, nil
+}
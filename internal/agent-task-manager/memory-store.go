@@ -0,0 +1,96 @@
+package agentmanager
+
+import "sync"
+
+type memoryTaskStore struct {
+	records   map[string]TaskRecord
+	schedules map[string]Schedule
+	mu        sync.RWMutex
+}
+
+var _ TaskStore = &memoryTaskStore{}
+
+// NewMemoryTaskStore returns a TaskStore that does not survive restarts
+func NewMemoryTaskStore() TaskStore {
+	return &memoryTaskStore{records: make(map[string]TaskRecord), schedules: make(map[string]Schedule)}
+}
+
+func (s *memoryTaskStore) Get(id string) (TaskRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[id]
+	return record, ok, nil
+}
+
+func (s *memoryTaskStore) Save(record TaskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *memoryTaskStore) Update(id string, fn func(r *TaskRecord)) (TaskRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[id]
+	if !ok {
+		return TaskRecord{}, false, nil
+	}
+	fn(&record)
+	s.records[id] = record
+	return record, true, nil
+}
+
+func (s *memoryTaskStore) List() ([]TaskRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]TaskRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *memoryTaskStore) Delete(ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+func (s *memoryTaskStore) GetSchedule(id string) (Schedule, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedule, ok := s.schedules[id]
+	return schedule, ok, nil
+}
+
+func (s *memoryTaskStore) SaveSchedule(schedule Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (s *memoryTaskStore) DeleteSchedule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules, id)
+	return nil
+}
+
+func (s *memoryTaskStore) ListSchedules() ([]Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func (s *memoryTaskStore) Close() error {
+	return nil
+}
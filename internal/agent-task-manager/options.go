@@ -0,0 +1,50 @@
+package agentmanager
+
+import "time"
+
+// QueueOption configures a task when it is queued
+type QueueOption func(r *TaskRecord)
+
+// WithCallbackURL posts the task result to url once the task finishes
+func WithCallbackURL(url string) QueueOption {
+	return func(r *TaskRecord) {
+		r.CallbackURL = url
+	}
+}
+
+// WithIdempotencyKey makes QueueTask return the task previously queued with the same key
+// instead of queueing a new one. Keys are remembered for idempotencyKeyTTL.
+func WithIdempotencyKey(key string) QueueOption {
+	return func(r *TaskRecord) {
+		r.IdempotencyKey = key
+	}
+}
+
+// WithFailureThreshold overrides the share of child tasks that may fail, see Config.ChildFailureThreshold
+func WithFailureThreshold(threshold *float64) QueueOption {
+	return func(r *TaskRecord) {
+		r.FailureThreshold = threshold
+	}
+}
+
+// WithTimeout overrides how long the task may run, up to the server maximum
+func WithTimeout(timeout time.Duration) QueueOption {
+	return func(r *TaskRecord) {
+		r.Timeout = timeout
+	}
+}
+
+// WithMaxParallelChildren overrides how many children of the task run at the same time,
+// up to the number of workers
+func WithMaxParallelChildren(n int) QueueOption {
+	return func(r *TaskRecord) {
+		r.MaxParallelChildren = n
+	}
+}
+
+// WithRequestedBy records the API key identity that queued the task
+func WithRequestedBy(identity string) QueueOption {
+	return func(r *TaskRecord) {
+		r.RequestedBy = identity
+	}
+}
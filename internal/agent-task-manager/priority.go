@@ -0,0 +1,24 @@
+package agentmanager
+
+import agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+
+// Priority classes, higher runs first
+const (
+	PriorityBulk        = 0
+	PriorityInteractive = 10
+)
+
+// Someone is usually waiting on single pages and transcripts in the admin,
+// whole site conversions take minutes anyway
+var defaultPriorities = map[string]int{
+	agenttask.TaskTypeConvertPage:       PriorityInteractive,
+	agenttask.TaskTypeConvertSite:       PriorityBulk,
+	agenttask.TaskTypeYoutubeTranscript: PriorityInteractive,
+}
+
+func (a *AgentTaskManager) priority(taskType string) int {
+	if priority, ok := a.priorities[taskType]; ok {
+		return priority
+	}
+	return PriorityBulk
+}
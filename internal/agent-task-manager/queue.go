@@ -0,0 +1,268 @@
+package agentmanager
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// QueueEntry is a task waiting in a QueueBackend. Entries only refer to the task by id and type, so a
+// backend can hand them to workers in other processes, which rebuild the task from its record.
+type QueueEntry struct {
+	TaskID   string    `json:"task_id"`
+	TaskType string    `json:"task_type"`
+	Priority int       `json:"priority"`
+	QueuedAt time.Time `json:"queued_at"`
+	// NotBefore holds the entry back until then, e.g. while waiting for a retry
+	NotBefore time.Time `json:"not_before,omitzero"`
+	// At most GroupLimit tasks of the same Group run at once, 0 means no limit
+	Group      string `json:"group,omitempty"`
+	GroupLimit int    `json:"group_limit,omitempty"`
+	// Seq keeps FIFO order between tasks with the same priority, it is set by the backend
+	Seq uint64 `json:"seq"`
+	// Reclaimed is set when the entry is handed out again because the worker that had it stopped
+	Reclaimed bool `json:"reclaimed,omitempty"`
+
+	// task is kept by the in-memory backend so the task isn't rebuilt
+	task agenttask.AgentTask
+}
+
+// due reports whether the entry may be popped at now
+func (e QueueEntry) due(now time.Time) bool {
+	return !e.NotBefore.After(now)
+}
+
+// QueueBackend holds the tasks waiting for a worker, highest priority first.
+//
+// Tasks gain one priority point for every aging interval they wait, so low priority tasks
+// eventually run even while higher priority tasks keep arriving.
+type QueueBackend interface {
+	Push(entry QueueEntry) error
+	// Pop waits for the due entry with the highest priority whose group is under its limit, it returns
+	// false once ctx is done. Call Done once the task finished running.
+	Pop(ctx context.Context) (QueueEntry, bool)
+	// Done releases the group slot of a popped entry
+	Done(id string)
+	// Remove takes an entry out of the queue, it returns false if the task isn't queued
+	Remove(id string) bool
+	// Snapshot returns the due entries in the order they would be popped right now
+	Snapshot() []QueueEntry
+	// Queued returns every queued entry, including the ones held back by NotBefore, in no particular order
+	Queued() []QueueEntry
+	// Durable reports whether entries survive restarts and can be popped by other processes
+	Durable() bool
+	Close() error
+}
+
+// queueOrder picks entries highest effective priority first, then in the order they were pushed
+type queueOrder struct {
+	aging time.Duration
+}
+
+// before reports whether a should be popped before b
+func (o queueOrder) before(a, b QueueEntry, now time.Time) bool {
+	aPriority, bPriority := o.effectivePriority(a, now), o.effectivePriority(b, now)
+	if aPriority != bPriority {
+		return aPriority > bPriority
+	}
+	return a.Seq < b.Seq
+}
+
+func (o queueOrder) effectivePriority(entry QueueEntry, now time.Time) int {
+	if o.aging <= 0 {
+		return entry.Priority
+	}
+	return entry.Priority + int(now.Sub(entry.QueuedAt)/o.aging)
+}
+
+// next returns the index of the entry to pop, or -1 if none is due and under its group limit
+func (o queueOrder) next(entries []QueueEntry, running map[string]int, now time.Time) int {
+	next := -1
+	for i, entry := range entries {
+		if !entry.due(now) || (entry.GroupLimit > 0 && running[entry.Group] >= entry.GroupLimit) {
+			continue
+		}
+		if next < 0 || o.before(entry, entries[next], now) {
+			next = i
+		}
+	}
+	return next
+}
+
+// sorted returns the due entries in the order they would be popped
+func (o queueOrder) sorted(entries []QueueEntry, now time.Time) []QueueEntry {
+	due := make([]QueueEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.due(now) {
+			due = append(due, entry)
+		}
+	}
+	slices.SortFunc(due, func(a, b QueueEntry) int {
+		if o.before(a, b, now) {
+			return -1
+		}
+		if o.before(b, a, now) {
+			return 1
+		}
+		return 0
+	})
+	return due
+}
+
+// memoryQueue is a QueueBackend that lives in the process. Unlike a channel, queued tasks can be
+// removed before a worker picks them up.
+type memoryQueue struct {
+	order   queueOrder
+	entries []QueueEntry
+	nextSeq uint64
+	mu      sync.Mutex
+
+	// ready has a value when there may be entries to pop
+	ready chan struct{}
+
+	// running counts the popped entries of each group until Done is called
+	running map[string]int
+	// popped is the group of each popped entry
+	popped map[string]string
+}
+
+var _ QueueBackend = &memoryQueue{}
+
+// NewMemoryQueue returns a QueueBackend that does not survive restarts
+func NewMemoryQueue(aging time.Duration) QueueBackend {
+	return &memoryQueue{
+		order:   queueOrder{aging: aging},
+		ready:   make(chan struct{}, 1),
+		running: make(map[string]int),
+		popped:  make(map[string]string),
+	}
+}
+
+func (q *memoryQueue) Push(entry QueueEntry) error {
+	q.mu.Lock()
+	entry.QueuedAt = time.Now()
+	entry.Seq = q.nextSeq
+	q.entries = append(q.entries, entry)
+	q.nextSeq++
+	q.mu.Unlock()
+
+	q.signal()
+	return nil
+}
+
+func (q *memoryQueue) Pop(ctx context.Context) (QueueEntry, bool) {
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		if next := q.order.next(q.entries, q.running, now); next >= 0 {
+			entry := q.entries[next]
+			q.entries = slices.Delete(q.entries, next, next+1)
+			if entry.Group != "" {
+				q.running[entry.Group]++
+				q.popped[entry.TaskID] = entry.Group
+			}
+			more := len(q.entries) > 0
+			q.mu.Unlock()
+
+			// Wake up another worker for the rest
+			if more {
+				q.signal()
+			}
+			return entry, true
+		}
+		wait := q.untilDue(now)
+		q.mu.Unlock()
+
+		// Entries held back by NotBefore become due without a push
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-q.ready:
+		case <-due:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return QueueEntry{}, false
+		}
+	}
+}
+
+// untilDue returns how long until the next held back entry is due, 0 if there is none. q.mu must be held.
+func (q *memoryQueue) untilDue(now time.Time) time.Duration {
+	var wait time.Duration
+	for _, entry := range q.entries {
+		if until := entry.NotBefore.Sub(now); until > 0 && (wait == 0 || until < wait) {
+			wait = until
+		}
+	}
+	return wait
+}
+
+func (q *memoryQueue) Done(id string) {
+	q.mu.Lock()
+	group, ok := q.popped[id]
+	if ok {
+		delete(q.popped, id)
+		q.running[group]--
+		if q.running[group] <= 0 {
+			delete(q.running, group)
+		}
+	}
+	q.mu.Unlock()
+
+	// Tasks of the group may be waiting for the slot
+	if ok {
+		q.signal()
+	}
+}
+
+func (q *memoryQueue) Snapshot() []QueueEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.order.sorted(q.entries, time.Now())
+}
+
+func (q *memoryQueue) Queued() []QueueEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.entries)
+}
+
+func (q *memoryQueue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, entry := range q.entries {
+		if entry.TaskID == id {
+			q.entries = slices.Delete(q.entries, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *memoryQueue) Durable() bool {
+	return false
+}
+
+func (q *memoryQueue) Close() error {
+	return nil
+}
+
+func (q *memoryQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,157 @@
+package agentmanager
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// queueBackends builds a fresh queue of every backend for each test
+var queueBackends = map[string]func(t *testing.T) QueueBackend{
+	"memory": func(t *testing.T) QueueBackend {
+		return NewMemoryQueue(0)
+	},
+	"bolt": func(t *testing.T) QueueBackend {
+		store, queue, err := NewSharedBoltStore(filepath.Join(t.TempDir(), "tasks.db"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			queue.Close()
+			store.Close()
+		})
+		return queue
+	},
+}
+
+func forEachQueue(t *testing.T, test func(t *testing.T, queue QueueBackend)) {
+	for name, newQueue := range queueBackends {
+		t.Run(name, func(t *testing.T) {
+			test(t, newQueue(t))
+		})
+	}
+}
+
+func push(t *testing.T, queue QueueBackend, entry QueueEntry) {
+	t.Helper()
+	if err := queue.Push(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pop pops the next entry, or fails the test if none is popped within wait
+func pop(t *testing.T, queue QueueBackend, wait time.Duration) QueueEntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	entry, ok := queue.Pop(ctx)
+	if !ok {
+		t.Fatal("nothing popped")
+	}
+	return entry
+}
+
+// popNothing fails the test if an entry is popped within wait
+func popNothing(t *testing.T, queue QueueBackend, wait time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	if entry, ok := queue.Pop(ctx); ok {
+		t.Fatal("popped", entry.TaskID)
+	}
+}
+
+func TestQueuePopOrder(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, queue QueueBackend) {
+		push(t, queue, QueueEntry{TaskID: "low", Priority: 0})
+		push(t, queue, QueueEntry{TaskID: "high", Priority: 10})
+		push(t, queue, QueueEntry{TaskID: "high-later", Priority: 10})
+
+		for _, want := range []string{"high", "high-later", "low"} {
+			if got := pop(t, queue, time.Second).TaskID; got != want {
+				t.Fatalf("popped %s, want %s", got, want)
+			}
+			queue.Done(want)
+		}
+	})
+}
+
+func TestQueueGroupLimit(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, queue QueueBackend) {
+		push(t, queue, QueueEntry{TaskID: "child-1", Priority: 5, Group: "parent", GroupLimit: 1})
+		push(t, queue, QueueEntry{TaskID: "child-2", Priority: 5, Group: "parent", GroupLimit: 1})
+		push(t, queue, QueueEntry{TaskID: "other", Priority: 0})
+
+		if got := pop(t, queue, time.Second).TaskID; got != "child-1" {
+			t.Fatalf("popped %s, want child-1", got)
+		}
+		// The group is at its limit, so the lower priority task goes first
+		if got := pop(t, queue, time.Second).TaskID; got != "other" {
+			t.Fatalf("popped %s, want other", got)
+		}
+		popNothing(t, queue, 100*time.Millisecond)
+
+		queue.Done("child-1")
+		if got := pop(t, queue, 3*time.Second).TaskID; got != "child-2" {
+			t.Fatalf("popped %s, want child-2", got)
+		}
+	})
+}
+
+func TestQueueRemove(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, queue QueueBackend) {
+		push(t, queue, QueueEntry{TaskID: "a"})
+		push(t, queue, QueueEntry{TaskID: "b"})
+
+		if !queue.Remove("a") {
+			t.Fatal("a was not removed")
+		}
+		if queue.Remove("a") {
+			t.Fatal("a was removed twice")
+		}
+		if got := pop(t, queue, time.Second).TaskID; got != "b" {
+			t.Fatalf("popped %s, want b", got)
+		}
+		// Popped entries are no longer queued
+		if queue.Remove("b") {
+			t.Fatal("popped b was removed")
+		}
+		if snapshot := queue.Snapshot(); len(snapshot) != 0 {
+			t.Fatalf("snapshot has %d entries, want 0", len(snapshot))
+		}
+	})
+}
+
+func TestQueueNotBefore(t *testing.T) {
+	forEachQueue(t, func(t *testing.T, queue QueueBackend) {
+		push(t, queue, QueueEntry{TaskID: "retry", NotBefore: time.Now().Add(200 * time.Millisecond)})
+
+		if snapshot := queue.Snapshot(); len(snapshot) != 0 {
+			t.Fatalf("snapshot has %d entries before the entry is due, want 0", len(snapshot))
+		}
+		if queued := queue.Queued(); len(queued) != 1 {
+			t.Fatalf("%d entries queued, want 1", len(queued))
+		}
+		if got := pop(t, queue, 3*time.Second).TaskID; got != "retry" {
+			t.Fatalf("popped %s, want retry", got)
+		}
+	})
+}
+
+func TestQueueOrderAging(t *testing.T) {
+	order := queueOrder{aging: time.Minute}
+	now := time.Now()
+	entries := []QueueEntry{
+		{TaskID: "new", Priority: 5, QueuedAt: now, Seq: 1},
+		// Waited long enough to gain 10 points
+		{TaskID: "old", Priority: 0, QueuedAt: now.Add(-10 * time.Minute), Seq: 0},
+	}
+
+	if next := order.next(entries, nil, now); entries[next].TaskID != "old" {
+		t.Fatalf("next is %s, want old", entries[next].TaskID)
+	}
+	if sorted := order.sorted(entries, now); sorted[0].TaskID != "old" || sorted[1].TaskID != "new" {
+		t.Fatalf("sorted %s, %s, want old, new", sorted[0].TaskID, sorted[1].TaskID)
+	}
+}
@@ -0,0 +1,45 @@
+package agentmanager
+
+import agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+
+// taskReporter records the updates of a running task
+type taskReporter struct {
+	manager *AgentTaskManager
+	taskID  string
+}
+
+var _ agenttask.Reporter = &taskReporter{}
+
+func (r *taskReporter) ReportProgress(progress agenttask.Progress) {
+	r.manager.updateTask(r.taskID, func(record *TaskRecord) {
+		record.Progress = &progress
+	})
+	r.manager.events.publish(TaskEvent{Type: TaskEventProgress, TaskID: r.taskID, Progress: &progress})
+}
+
+// Agent events are only streamed to subscribers, there are too many to store
+func (r *taskReporter) ReportAgentEvent(event agenttask.AgentEvent) {
+	r.manager.events.publish(TaskEvent{Type: TaskEventAgent, TaskID: r.taskID, Agent: &event})
+}
+
+func (r *taskReporter) SpawnChild(key string, task agenttask.AgentTask) error {
+	return r.manager.spawnChild(r.taskID, key, task)
+}
+
+func (r *taskReporter) SaveCheckpoint(key string, value string) {
+	r.manager.updateTask(r.taskID, func(record *TaskRecord) {
+		checkpoint := record.Checkpoints[key]
+		checkpoint.Value = value
+		record.setCheckpoint(key, checkpoint)
+	})
+}
+
+func (r *taskReporter) Checkpoints() map[string]agenttask.Checkpoint {
+	return r.manager.checkpoints(r.taskID)
+}
+
+func (r *taskReporter) ReportDocument(document agenttask.Document) {
+	r.manager.updateTask(r.taskID, func(record *TaskRecord) {
+		record.Documents = append(record.Documents, document)
+	})
+}
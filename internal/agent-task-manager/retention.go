@@ -0,0 +1,66 @@
+package agentmanager
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// pruneInterval is how often finished tasks past the retention are deleted
+const pruneInterval = time.Hour
+
+// pruneFinished deletes the tasks past the retention now and every pruneInterval until ctx is done
+func (a *AgentTaskManager) pruneFinished(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.prune(time.Now().Add(-a.retention)); err != nil {
+			log.Println("Error pruning finished tasks:", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// prune deletes the tasks that finished before cutoff, with their children. Children are only
+// deleted with their parent, and dead-lettered tasks are kept until they are resumed or replayed.
+func (a *AgentTaskManager) prune(cutoff time.Time) error {
+	records, err := a.store.List()
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]TaskRecord, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+
+	var ids []string
+	var collect func(record TaskRecord)
+	collect = func(record TaskRecord) {
+		ids = append(ids, record.ID)
+		for _, childID := range record.ChildIDs {
+			if child, ok := byID[childID]; ok {
+				collect(child)
+			}
+		}
+	}
+	for _, record := range records {
+		if record.ParentID != "" || !record.IsFinished() || record.IsDeadLettered() ||
+			record.FinishedAt == nil || record.FinishedAt.After(cutoff) {
+			continue
+		}
+		collect(record)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	log.Println("Pruning", len(ids), "finished tasks")
+	return a.store.Delete(ids...)
+}
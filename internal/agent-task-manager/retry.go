@@ -0,0 +1,55 @@
+package agentmanager
+
+import (
+	"math/rand/v2"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// RetryPolicy controls how often a failed task is attempted again
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 means never retry
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+var defaultRetryPolicies = map[string]RetryPolicy{
+	agenttask.TaskTypeConvertPage: {
+		MaxAttempts:    3,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+	},
+	// Whole site conversions are expensive, only try once more
+	agenttask.TaskTypeConvertSite: {
+		MaxAttempts:    2,
+		InitialBackoff: 1 * time.Minute,
+		MaxBackoff:     10 * time.Minute,
+	},
+	agenttask.TaskTypeYoutubeTranscript: {
+		MaxAttempts:    3,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+	},
+}
+
+// Backoff returns how long to wait before the next attempt, given the number of attempts made so far.
+// The delay doubles after each attempt and half of it is randomized so retries don't line up.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,81 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// Schedule queues a task every time its cron expression fires
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Cron is a standard cron expression with 5 fields, e.g. "0 15 * * SUN", or a descriptor like "@daily"
+	Cron string `json:"cron"`
+	// Timezone is the IANA zone Cron is evaluated in, UTC if empty
+	Timezone string `json:"timezone,omitempty"`
+	// Until stops the schedule after this time, e.g. once an old site is cut over
+	Until   *time.Time `json:"until,omitempty"`
+	Enabled bool       `json:"enabled"`
+
+	// The task queued every run
+	TaskType string          `json:"task_type"`
+	Params   json.RawMessage `json:"params"`
+	// Timeout overrides how long the task may run, 0 uses the default of the task type
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	CallbackURL string        `json:"callback_url,omitempty"`
+
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastTaskID string     `json:"last_task_id,omitempty"`
+	// LastError is set when the last run couldn't queue its task
+	LastError string `json:"last_error,omitempty"`
+}
+
+// spec parses the cron expression in the schedule's timezone
+func (s Schedule) spec() (cron.Schedule, error) {
+	expression := s.Cron
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %s", ErrInvalidSchedule, s.Timezone)
+		}
+		expression = "CRON_TZ=" + s.Timezone + " " + expression
+	}
+	spec, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	return spec, nil
+}
+
+// expired reports whether the schedule won't run anymore because Until has passed
+func (s Schedule) expired(now time.Time) bool {
+	return s.Until != nil && now.After(*s.Until)
+}
+
+// NextRunAt returns when the schedule runs next, nil if it is disabled or expired
+func (s Schedule) NextRunAt() *time.Time {
+	if !s.Enabled {
+		return nil
+	}
+	spec, err := s.spec()
+	if err != nil {
+		return nil
+	}
+	next := spec.Next(time.Now())
+	if next.IsZero() || s.expired(next) {
+		return nil
+	}
+	return &next
+}
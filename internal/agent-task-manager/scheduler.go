@@ -0,0 +1,252 @@
+package agentmanager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// actorSchedule is the actor of tasks queued by a schedule, followed by the schedule id
+const actorSchedule = "schedule:"
+
+// Scheduler queues tasks through the task manager on the schedules saved in its store
+type Scheduler struct {
+	manager *AgentTaskManager
+	store   ScheduleStore
+	cron    *cron.Cron
+
+	// entries are the cron entries of the enabled schedules by schedule id
+	entries map[string]cron.EntryID
+	mu      sync.Mutex
+}
+
+func NewScheduler(manager *AgentTaskManager) *Scheduler {
+	return &Scheduler{
+		manager: manager,
+		store:   manager.store,
+		cron:    cron.New(),
+		entries: make(map[string]cron.EntryID),
+	}
+}
+
+// Start schedules the saved schedules and starts running them
+func (s *Scheduler) Start() error {
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, schedule := range schedules {
+		if err := s.add(schedule); err != nil {
+			log.Println("Error scheduling", schedule.ID+":", err)
+		}
+	}
+	s.mu.Unlock()
+
+	s.cron.Start()
+	return nil
+}
+
+// Stop stops running schedules, it waits until ctx is done for runs that already started
+func (s *Scheduler) Stop(ctx context.Context) {
+	select {
+	case <-s.cron.Stop().Done():
+	case <-ctx.Done():
+	}
+}
+
+// Create saves a new schedule and schedules it
+func (s *Scheduler) Create(schedule Schedule) (Schedule, error) {
+	if err := s.validate(schedule); err != nil {
+		return Schedule{}, err
+	}
+	now := time.Now()
+	schedule.ID = uuid.New().String()
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.store.SaveSchedule(schedule); err != nil {
+		return Schedule{}, err
+	}
+	if err := s.add(schedule); err != nil {
+		return Schedule{}, err
+	}
+	log.Println("Created schedule", schedule.ID, schedule.Cron, schedule.TaskType)
+	return schedule, nil
+}
+
+// Update replaces the definition of a schedule, keeping its id, creation and last run
+func (s *Scheduler) Update(id string, update Schedule) (Schedule, error) {
+	if err := s.validate(update); err != nil {
+		return Schedule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok, err := s.store.GetSchedule(id)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if !ok {
+		return Schedule{}, ErrScheduleNotFound
+	}
+
+	schedule.Name = update.Name
+	schedule.Cron = update.Cron
+	schedule.Timezone = update.Timezone
+	schedule.Until = update.Until
+	schedule.Enabled = update.Enabled
+	schedule.TaskType = update.TaskType
+	schedule.Params = update.Params
+	schedule.Timeout = update.Timeout
+	schedule.CallbackURL = update.CallbackURL
+	schedule.UpdatedAt = time.Now()
+
+	if err := s.store.SaveSchedule(schedule); err != nil {
+		return Schedule{}, err
+	}
+	s.remove(id)
+	if err := s.add(schedule); err != nil {
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// Delete unschedules and deletes a schedule. Tasks it already queued are kept.
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok, err := s.store.GetSchedule(id); err != nil {
+		return err
+	} else if !ok {
+		return ErrScheduleNotFound
+	}
+
+	s.remove(id)
+	return s.store.DeleteSchedule(id)
+}
+
+func (s *Scheduler) Get(id string) (Schedule, bool) {
+	schedule, ok, err := s.store.GetSchedule(id)
+	if err != nil {
+		log.Println("Error getting schedule", id+":", err)
+		return Schedule{}, false
+	}
+	return schedule, ok
+}
+
+// List returns every schedule, oldest first
+func (s *Scheduler) List() ([]Schedule, error) {
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(schedules, func(a, b Schedule) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	if schedules == nil {
+		schedules = []Schedule{}
+	}
+	return schedules, nil
+}
+
+// validate checks the cron expression and that the task can be built from its type and params
+func (s *Scheduler) validate(schedule Schedule) error {
+	if _, err := schedule.spec(); err != nil {
+		return err
+	}
+	if _, err := s.manager.restore(uuid.New().String(), schedule.TaskType, schedule.Params); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	return nil
+}
+
+// add schedules an enabled schedule, s.mu must be held
+func (s *Scheduler) add(schedule Schedule) error {
+	if !schedule.Enabled || schedule.expired(time.Now()) {
+		return nil
+	}
+	spec, err := schedule.spec()
+	if err != nil {
+		return err
+	}
+	s.entries[schedule.ID] = s.cron.Schedule(spec, cron.FuncJob(func() {
+		s.run(schedule.ID)
+	}))
+	return nil
+}
+
+// remove unschedules a schedule, s.mu must be held
+func (s *Scheduler) remove(id string) {
+	if entry, ok := s.entries[id]; ok {
+		s.cron.Remove(entry)
+		delete(s.entries, id)
+	}
+}
+
+// run queues the task of a schedule and records the outcome on the schedule
+func (s *Scheduler) run(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok, err := s.store.GetSchedule(id)
+	if err != nil || !ok {
+		log.Println("Error getting schedule", id+":", err)
+		return
+	}
+
+	now := time.Now()
+	if schedule.expired(now) {
+		log.Println("Schedule", id, "expired")
+		s.remove(id)
+		schedule.Enabled = false
+		schedule.UpdatedAt = now
+		if err := s.store.SaveSchedule(schedule); err != nil {
+			log.Println("Error saving schedule", id+":", err)
+		}
+		return
+	}
+
+	schedule.LastRunAt = &now
+	schedule.LastTaskID = ""
+	schedule.LastError = ""
+
+	// Identical tasks that are still queued or running are not queued again, the run then
+	// points to the earlier task
+	taskID, err := s.queue(schedule)
+	if err != nil {
+		log.Println("Error running schedule", id+":", err)
+		schedule.LastError = err.Error()
+	} else {
+		log.Println("Schedule", id, "queued task", taskID)
+		schedule.LastTaskID = taskID
+	}
+
+	if err := s.store.SaveSchedule(schedule); err != nil {
+		log.Println("Error saving schedule", id+":", err)
+	}
+}
+
+func (s *Scheduler) queue(schedule Schedule) (string, error) {
+	task, err := s.manager.restore(uuid.New().String(), schedule.TaskType, schedule.Params)
+	if err != nil {
+		return "", fmt.Errorf("error restoring task: %w", err)
+	}
+	return s.manager.QueueTask(task,
+		WithCallbackURL(schedule.CallbackURL),
+		WithTimeout(schedule.Timeout),
+		WithRequestedBy(actorSchedule+schedule.ID))
+}
@@ -0,0 +1,188 @@
+package agentmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+)
+
+// TaskRecord is the persisted state of a task
+type TaskRecord struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Params     json.RawMessage `json:"params"`
+	Status     TaskStatus      `json:"status"`
+	Priority   int             `json:"priority"`
+	Error      string          `json:"error,omitempty"`
+	ErrorCode  string          `json:"error_code,omitempty"`
+	ErrorChain []string        `json:"error_chain,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+
+	// Timeout is how long each attempt may run
+	Timeout             time.Duration `json:"timeout,omitempty"`
+	MaxParallelChildren int           `json:"max_parallel_children,omitempty"`
+
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+
+	Progress  *agenttask.Progress  `json:"progress,omitempty"`
+	Documents []agenttask.Document `json:"documents,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// RequestedBy is the API key identity that queued the task
+	RequestedBy string `json:"requested_by,omitempty"`
+	CancelledBy string `json:"cancelled_by,omitempty"`
+	// CancelRequested asks the worker running the task in another process to cancel it
+	CancelRequested bool `json:"cancel_requested,omitempty"`
+	// WorkerID is the worker that last ran the task
+	WorkerID string       `json:"worker_id,omitempty"`
+	History  []Transition `json:"history,omitempty"`
+	// actor is who causes the status change being saved, see Transition
+	actor string
+
+	// DeadLetteredAt is set when the task failed for good, until it is resumed
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+	// ReplayOf is the dead-lettered task this task replays, ReplayedBy the task replaying this one
+	ReplayOf   string `json:"replay_of,omitempty"`
+	ReplayedBy string `json:"replayed_by,omitempty"`
+
+	ParentID string   `json:"parent_id,omitempty"`
+	ChildIDs []string `json:"child_ids,omitempty"`
+	// AwaitingChildren is set once Execute returned and the task only waits for its children
+	AwaitingChildren bool `json:"awaiting_children,omitempty"`
+	// Children is the outcome of each child, updated as they finish
+	Children         []ChildOutcome `json:"children,omitempty"`
+	FailureThreshold *float64       `json:"failure_threshold,omitempty"`
+	// Checkpoints are the units of work saved by the task, by key
+	Checkpoints map[string]TaskCheckpoint `json:"checkpoints,omitempty"`
+
+	CallbackURL        string             `json:"callback_url,omitempty"`
+	CallbackDeliveries []CallbackDelivery `json:"callback_deliveries,omitempty"`
+}
+
+// IsFinished reports whether the task reached a terminal status
+func (r TaskRecord) IsFinished() bool {
+	return r.Status.IsFinished()
+}
+
+// TaskCheckpoint is a unit of work saved by a task, see agenttask.Reporter
+type TaskCheckpoint struct {
+	Value string `json:"value,omitempty"`
+	// ChildID is the last child spawned for the checkpoint
+	ChildID string `json:"child_id,omitempty"`
+}
+
+func (r *TaskRecord) setCheckpoint(key string, checkpoint TaskCheckpoint) {
+	if r.Checkpoints == nil {
+		r.Checkpoints = make(map[string]TaskCheckpoint)
+	}
+	r.Checkpoints[key] = checkpoint
+}
+
+// IsDeadLettered reports whether the task failed for good and wasn't replayed yet
+func (r TaskRecord) IsDeadLettered() bool {
+	return r.Status == TaskStatusFailed && r.DeadLetteredAt != nil && r.ReplayedBy == ""
+}
+
+// setError records why the task failed, nil clears it
+func (r *TaskRecord) setError(err error) {
+	if err == nil {
+		r.Error = ""
+		r.ErrorCode = ""
+		r.ErrorChain = nil
+		return
+	}
+	r.Error = err.Error()
+	r.ErrorCode = agenttask.ErrorCode(err)
+	r.ErrorChain = agenttask.ErrorChain(err)
+}
+
+// TaskResult summarizes the Payload documents a task produced
+type TaskResult struct {
+	CreatedPageIDs []string `json:"created_page_ids"`
+	UpdatedPageIDs []string `json:"updated_page_ids"`
+	MediaIDs       []string `json:"media_ids"`
+	PostID         string   `json:"post_id,omitempty"`
+}
+
+// Result groups the documents reported by the task
+func (r TaskRecord) Result() TaskResult {
+	result := TaskResult{
+		CreatedPageIDs: []string{},
+		UpdatedPageIDs: []string{},
+		MediaIDs:       []string{},
+	}
+
+	seen := make(map[agenttask.Document]bool)
+	for _, document := range r.Documents {
+		// Retried attempts report the same documents again
+		if seen[document] {
+			continue
+		}
+		seen[document] = true
+
+		switch {
+		case document.Collection == "pages" && document.Action == agenttask.DocumentCreated:
+			result.CreatedPageIDs = append(result.CreatedPageIDs, document.ID)
+		case document.Collection == "pages":
+			result.UpdatedPageIDs = append(result.UpdatedPageIDs, document.ID)
+		case document.Collection == "media":
+			result.MediaIDs = append(result.MediaIDs, document.ID)
+		case document.Collection == "posts":
+			result.PostID = document.ID
+		}
+	}
+	return result
+}
+
+// TaskStore persists task records
+type TaskStore interface {
+	// Get returns the record for the given task id, ok is false if it doesn't exist
+	Get(id string) (record TaskRecord, ok bool, err error)
+	Save(record TaskRecord) error
+	// Update applies fn to the record of a task and saves it in one step, so updates from other
+	// processes sharing the store aren't lost. ok is false if the task doesn't exist.
+	Update(id string, fn func(r *TaskRecord)) (record TaskRecord, ok bool, err error)
+	List() ([]TaskRecord, error)
+	// Delete removes the records of the given tasks, ids that don't exist are skipped
+	Delete(ids ...string) error
+	Close() error
+
+	// Schedules are stored alongside the tasks they queue
+	ScheduleStore
+}
+
+type ScheduleStore interface {
+	// GetSchedule returns the schedule with the given id, ok is false if it doesn't exist
+	GetSchedule(id string) (schedule Schedule, ok bool, err error)
+	SaveSchedule(schedule Schedule) error
+	DeleteSchedule(id string) error
+	ListSchedules() ([]Schedule, error)
+}
+
+// Queue backends, see Config
+const (
+	QueueMemory = "memory"
+	QueueBolt   = "bolt"
+)
+
+// OpenStore opens the task store and queue backend configured in cfg
+func OpenStore(cfg Config) (TaskStore, QueueBackend, error) {
+	switch cfg.Queue {
+	case QueueMemory, "":
+		store, err := NewBoltTaskStore(cfg.StorePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, NewMemoryQueue(cfg.PriorityAging), nil
+	case QueueBolt:
+		return NewSharedBoltStore(cfg.StorePath, cfg.PriorityAging)
+	}
+	return nil, nil, fmt.Errorf("unknown task queue: %s", cfg.Queue)
+}
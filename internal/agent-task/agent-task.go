@@ -2,13 +2,61 @@ package agenttask
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
+	"github.com/ForTheChurch/buildforthechurch/internal/scraper"
+	"github.com/docker/cagent/pkg/model/provider"
 	"github.com/google/uuid"
 )
 
+// Task type names, these are persisted with each task so they must not change
+const (
+	TaskTypeConvertPage       = "convert-page"
+	TaskTypeConvertSite       = "convert-site"
+	TaskTypeYoutubeTranscript = "youtube-transcript"
+)
+
 type AgentTask interface {
-	Execute(ctx context.Context) error
+	// Execute runs the task. The task manager sets the timeout of ctx.
+	Execute(ctx context.Context, reporter Reporter) error
 	ID() string
+	Type() string
+	// Params returns the input parameters of the task. It must be JSON
+	// serializable so the task can be rebuilt with Restore.
+	Params() any
+}
+
+// Dependencies are the services a task needs to run
+type Dependencies struct {
+	Scraper          scraper.Scraper
+	PayloadCMSClient *payloadcms.Client
+	LLM              provider.Provider
+}
+
+// Constructor rebuilds a task of one type from its id and persisted parameters
+type Constructor func(id string, params json.RawMessage, deps Dependencies) (AgentTask, error)
+
+// constructors are the registered task types by name
+var constructors = make(map[string]Constructor)
+
+// Register makes a task type known to Restore, so tasks of the type can be serialized as their type
+// and params and rebuilt in any process, e.g. a worker. It panics if the type is already registered.
+func Register(taskType string, constructor Constructor) {
+	if _, ok := constructors[taskType]; ok {
+		panic("task type registered twice: " + taskType)
+	}
+	constructors[taskType] = constructor
+}
+
+// Restore rebuilds a task from its persisted type and parameters, keeping the original task id
+func Restore(id string, taskType string, params json.RawMessage, deps Dependencies) (AgentTask, error) {
+	constructor, ok := constructors[taskType]
+	if !ok {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+	return constructor(id, params, deps)
 }
 
 func newTaskId() string {
@@ -2,13 +2,13 @@ package agenttask
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"path/filepath"
-	"time"
 
 	"github.com/ForTheChurch/buildforthechurch/internal/pagecache"
 	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
@@ -30,6 +30,8 @@ type ConvertPageTask struct {
 	llm              provider.Provider
 	htmlCache        pagecache.PageCache
 	markdownCache    pagecache.PageCache
+	// refreshCache scrapes the page again even if it is cached
+	refreshCache bool
 }
 
 func NewConvertPageTask(url string, pageID string, firecrawlScraper scraper.Scraper, payloadCMSClient *payloadcms.Client, llm provider.Provider) *ConvertPageTask {
@@ -47,16 +49,42 @@ func NewConvertPageTask(url string, pageID string, firecrawlScraper scraper.Scra
 
 var _ AgentTask = &ConvertPageTask{}
 
+func init() {
+	Register(TaskTypeConvertPage, restoreConvertPageTask)
+}
+
+func restoreConvertPageTask(id string, params json.RawMessage, deps Dependencies) (AgentTask, error) {
+	var p ConvertPageParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("error unmarshalling convert page params: %w", err)
+	}
+	t := NewConvertPageTask(p.URL, p.PageID, deps.Scraper, deps.PayloadCMSClient, deps.LLM)
+	t.id = id
+	t.refreshCache = p.RefreshCache
+	return t, nil
+}
+
+// ConvertPageParams are the input parameters of a ConvertPageTask
+type ConvertPageParams struct {
+	URL          string `json:"url"`
+	PageID       string `json:"pageId"`
+	RefreshCache bool   `json:"refreshCache,omitempty"`
+}
+
 func (t *ConvertPageTask) ID() string {
 	return t.id
 }
 
-func (t *ConvertPageTask) Execute(ctx context.Context) error {
-	log.Println("[ConvertPageTask] Started for", t.url)
+func (t *ConvertPageTask) Type() string {
+	return TaskTypeConvertPage
+}
+
+func (t *ConvertPageTask) Params() any {
+	return ConvertPageParams{URL: t.url, PageID: t.pageID, RefreshCache: t.refreshCache}
+}
 
-	// 5 minute timeout
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
+func (t *ConvertPageTask) Execute(ctx context.Context, reporter Reporter) error {
+	log.Println("[ConvertPageTask] Started for", t.url)
 
 	var html string
 	var markdown string
@@ -65,15 +93,16 @@ func (t *ConvertPageTask) Execute(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("error getting cached page: %w", err)
 	}
-	if cachedPage != "" && cachedMarkdown != "" {
+	if !t.refreshCache && cachedPage != "" && cachedMarkdown != "" {
 		html = cachedPage
 		markdown = cachedMarkdown
 		log.Println("[ConvertPageTask] Using cached page")
 	} else {
 		log.Println("[ConvertPageTask] No cached page found, scraping page")
+		reporter.ReportProgress(Progress{Phase: PhaseScraping, CurrentURL: t.url})
 		html, markdown, err = t.scrapePage(ctx)
 		if err != nil {
-			return fmt.Errorf("error scraping page HTML: %w", err)
+			return WithCode(ErrorCodeScrapeFailed, fmt.Errorf("error scraping page HTML: %w", err))
 		}
 		if err := t.htmlCache.SetCachedPage(t.url, html); err != nil {
 			return fmt.Errorf("error caching page: %w", err)
@@ -85,9 +114,11 @@ func (t *ConvertPageTask) Execute(ctx context.Context) error {
 
 	convertPagePrompt, err := prompt.GetConvertPagePrompt()
 	if err != nil {
-		return fmt.Errorf("error getting convert page prompt: %w", err)
+		return Permanent(fmt.Errorf("error getting convert page prompt: %w", err))
 	}
 
+	reporter.ReportProgress(Progress{Phase: PhaseConverting, CurrentURL: t.url})
+
 	ctx, b, cleanup := newBail(ctx)
 	defer cleanup()
 
@@ -97,23 +128,25 @@ func (t *ConvertPageTask) Execute(ctx context.Context) error {
 		agent.WithModel(t.llm),
 		agent.WithDescription("An agent that converts church website HTML into a PayloadCMS Page JSON object."),
 		agent.WithTools(
-			b.BailAfterSuccessfulToolCall(toolExportPage("ConvertPageTask", t.pageID, t.payloadCMSClient)),
-			toolUploadMedia("ConvertPageTask", t.payloadCMSClient)),
+			b.BailAfterSuccessfulToolCall(reportOnToolCall(
+				toolExportPage("ConvertPageTask", t.pageID, t.payloadCMSClient, reporter),
+				reporter, Progress{Phase: PhaseExporting, CurrentURL: t.url})),
+			toolUploadMedia("ConvertPageTask", t.payloadCMSClient, reporter)),
 	)
 
 	agentTeam := team.New(team.WithAgents(rootAgent))
 
 	rt, err := runtime.New(agentTeam)
 	if err != nil {
-		return fmt.Errorf("error creating runtime: %w", err)
+		return WithCode(ErrorCodeAgentFailed, fmt.Errorf("error creating runtime: %w", err))
 	}
 
 	p := "The following markdown came from a church website at " + t.url + "\n\n" + markdown + "\n\n"
 	sess := session.New(session.WithUserMessage("", p))
 	sess.ToolsApproved = true
 
-	if _, err = rt.Run(ctx, sess); err != nil {
-		return fmt.Errorf("error running agent: %w", err)
+	if err = runAgent(ctx, rt, sess, reporter, t.url); err != nil {
+		return WithCode(ErrorCodeAgentFailed, fmt.Errorf("error running agent: %w", err))
 	}
 
 	log.Println("[ConvertPageTask] completed for", t.url)
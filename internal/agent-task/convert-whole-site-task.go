@@ -7,18 +7,11 @@ import (
 	"log"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/ForTheChurch/buildforthechurch/internal/pagecache"
 	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
-	"github.com/ForTheChurch/buildforthechurch/internal/prompt"
 	"github.com/ForTheChurch/buildforthechurch/internal/scraper"
-	"github.com/docker/cagent/pkg/agent"
 	"github.com/docker/cagent/pkg/model/provider"
-	"github.com/docker/cagent/pkg/runtime"
-	"github.com/docker/cagent/pkg/session"
-	"github.com/docker/cagent/pkg/team"
-	"golang.org/x/sync/errgroup"
 )
 
 // siteData is a map of page urls to their title and html
@@ -34,7 +27,10 @@ type ConvertWholeSiteTask struct {
 	payloadCMSClient *payloadcms.Client
 	llm              provider.Provider
 	pageCache        pagecache.PageCache
+	markdownCache    pagecache.PageCache
 	siteCache        pagecache.PageCache
+	// refreshCache crawls the site again even if it is cached
+	refreshCache bool
 }
 
 func NewConvertWholeSiteTask(url string, firecrawlScraper scraper.Scraper, payloadCMSClient *payloadcms.Client, llm provider.Provider) *ConvertWholeSiteTask {
@@ -45,63 +41,105 @@ func NewConvertWholeSiteTask(url string, firecrawlScraper scraper.Scraper, paylo
 		payloadCMSClient: payloadCMSClient,
 		llm:              llm,
 		pageCache:        pagecache.NewPageCache("html"),
+		markdownCache:    pagecache.NewPageCache("md"),
 		siteCache:        pagecache.NewPageCache("json"),
 	}
 }
 
 var _ AgentTask = &ConvertWholeSiteTask{}
 
+func init() {
+	Register(TaskTypeConvertSite, restoreConvertWholeSiteTask)
+}
+
+func restoreConvertWholeSiteTask(id string, params json.RawMessage, deps Dependencies) (AgentTask, error) {
+	var p ConvertWholeSiteParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("error unmarshalling convert site params: %w", err)
+	}
+	t := NewConvertWholeSiteTask(p.URL, deps.Scraper, deps.PayloadCMSClient, deps.LLM)
+	t.id = id
+	t.refreshCache = p.RefreshCache
+	return t, nil
+}
+
+// ConvertWholeSiteParams are the input parameters of a ConvertWholeSiteTask
+type ConvertWholeSiteParams struct {
+	URL          string `json:"url"`
+	RefreshCache bool   `json:"refreshCache,omitempty"`
+}
+
 func (t *ConvertWholeSiteTask) ID() string {
 	return t.id
 }
 
-func (t *ConvertWholeSiteTask) Execute(ctx context.Context) error {
-	log.Println("[ConvertWholeSiteTask] Started for", t.url)
+func (t *ConvertWholeSiteTask) Type() string {
+	return TaskTypeConvertSite
+}
+
+func (t *ConvertWholeSiteTask) Params() any {
+	return ConvertWholeSiteParams{URL: t.url, RefreshCache: t.refreshCache}
+}
 
-	// 5 minute timeout
-	ctx, cancel := context.WithTimeout(ctx, 300*time.Second)
-	defer cancel()
+func (t *ConvertWholeSiteTask) Execute(ctx context.Context, reporter Reporter) error {
+	log.Println("[ConvertWholeSiteTask] Started for", t.url)
 
 	// Check if we crawled this site already
-	siteData, err := t.getCachedSiteData()
-	if err != nil {
-		return fmt.Errorf("error getting cached site index: %w", err)
+	var siteData siteData
+	var err error
+	if !t.refreshCache {
+		siteData, err = t.getCachedSiteData()
+		if err != nil {
+			return fmt.Errorf("error getting cached site index: %w", err)
+		}
 	}
 	if siteData != nil {
 		log.Println("[ConvertWholeSiteTask] Using cached site index")
 	} else {
 		log.Println("[ConvertWholeSiteTask] No cached site index found, crawling site")
+		reporter.ReportProgress(Progress{Phase: PhaseCrawling, CurrentURL: t.url})
 		siteData, err = t.crawlSite(ctx)
 		if err != nil {
-			return fmt.Errorf("error crawling site: %w", err)
+			return WithCode(ErrorCodeCrawlFailed, fmt.Errorf("error crawling site: %w", err))
 		}
 		if err := t.setCachedSiteData(siteData); err != nil {
 			return fmt.Errorf("error caching site data: %w", err)
 		}
 	}
 
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(4) // 4 concurrent page conversions
+	// Pages created or exported by an earlier attempt are checkpointed by url
+	checkpoints := reporter.Checkpoints()
+
+	// Each page is converted by its own task so a failed page can be retried on its own
 	for url, data := range siteData {
-		eg.Go(func() error {
+		checkpoint := checkpoints[url]
+		if checkpoint.Done {
+			log.Println("[ConvertWholeSiteTask] Already converted", url)
+			continue
+		}
+
+		reporter.ReportProgress(Progress{Phase: PhaseConverting, CurrentURL: url})
+		pageId := checkpoint.Value
+		if pageId == "" {
 			slug, err := getPageSlug(url)
 			if err != nil {
-				return fmt.Errorf("error getting page slug: %w", err)
+				return Permanent(fmt.Errorf("error getting page slug: %w", err))
 			}
-			pageId, err := t.createPageInPayload(ctx, data.Title, slug)
+			pageId, err = t.createPageInPayload(ctx, data.Title, slug)
 			if err != nil {
-				return fmt.Errorf("error creating page in payload: %w", err)
+				return WithCode(ErrorCodeCMSFailed, fmt.Errorf("error creating page in payload: %w", err))
 			}
-			return t.convertPage(ctx, url, pageId, data)
-		})
-	}
+			reporter.ReportDocument(Document{Collection: "pages", ID: pageId, Action: DocumentCreated})
+			reporter.SaveCheckpoint(url, pageId)
+		}
 
-	err = eg.Wait()
-	if err != nil {
-		return fmt.Errorf("error converting pages: %w", err)
+		child := NewConvertPageTask(url, pageId, t.firecrawlScraper, t.payloadCMSClient, t.llm)
+		if err := reporter.SpawnChild(url, child); err != nil {
+			return fmt.Errorf("error queueing page conversion: %w", err)
+		}
 	}
 
-	log.Println("[ConvertWholeSiteTask] Completed for", t.url)
+	log.Println("[ConvertWholeSiteTask] Queued", len(siteData), "page conversions for", t.url)
 
 	return nil
 }
@@ -128,50 +166,6 @@ func (t *ConvertWholeSiteTask) createPageInPayload(ctx context.Context, title st
 	return t.payloadCMSClient.CreatePage(ctx, title, slug)
 }
 
-func (t *ConvertWholeSiteTask) convertPage(ctx context.Context, url string, pageID string, data struct {
-	Title string
-	Html  string
-}) error {
-	log.Println("[ConvertWholeSiteTask] Converting page at", url)
-
-	convertPagePrompt, err := prompt.GetConvertPagePrompt()
-	if err != nil {
-		return fmt.Errorf("error getting convert page prompt: %w", err)
-	}
-
-	ctx, b, cleanup := newBail(ctx)
-	defer cleanup()
-
-	rootAgent := agent.New(
-		"root",
-		convertPagePrompt,
-		agent.WithModel(t.llm),
-		agent.WithDescription("An agent that converts church website HTML into a PayloadCMS Page JSON object."),
-		agent.WithTools(
-			b.BailAfterSuccessfulToolCall(toolExportPage("ConvertWholeSiteTask", pageID, t.payloadCMSClient)),
-			toolUploadMedia("ConvertWholeSiteTask", t.payloadCMSClient)),
-	)
-
-	agentTeam := team.New(team.WithAgents(rootAgent))
-
-	rt, err := runtime.New(agentTeam)
-	if err != nil {
-		return fmt.Errorf("error creating runtime: %w", err)
-	}
-
-	p := "I retrieved the following HTML from a church website at " + t.url + "\n\n" + data.Html
-	sess := session.New(session.WithUserMessage("", p))
-	sess.ToolsApproved = true
-
-	if _, err = rt.Run(ctx, sess); err != nil {
-		return fmt.Errorf("error running agent: %w", err)
-	}
-
-	log.Println("[ConvertWholeSiteTask] Completed for page at", url)
-
-	return nil
-}
-
 func (t *ConvertWholeSiteTask) crawlSite(ctx context.Context) (siteData, error) {
 	log.Println("[ConvertWholeSiteTask] Crawling site at", t.url)
 	resultCh := t.firecrawlScraper.Crawl(t.url)
@@ -199,6 +193,11 @@ func (t *ConvertWholeSiteTask) crawlSite(ctx context.Context) (siteData, error)
 			Title: result.Metadata["title"],
 			Html:  result.Html,
 		}
+
+		// Saves the page conversions from scraping each page again
+		if err := t.markdownCache.SetCachedPage(url, result.Markdown); err != nil {
+			return nil, fmt.Errorf("error caching markdown: %w", err)
+		}
 	}
 
 	return siteData, nil
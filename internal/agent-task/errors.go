@@ -0,0 +1,154 @@
+package agenttask
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
+)
+
+// permanentError marks an error that will happen again if the task is retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err so the task is not retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable reports whether a task that failed with err might succeed on another attempt
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	// Payload or a proxy in front of it failed, it may be back by the next attempt
+	var serverError *payloadcms.ServerError
+	if errors.As(err, &serverError) {
+		return true
+	}
+
+	// Payload rejected the request, sending it again won't help
+	var statusError *payloadcms.StatusError
+	if errors.As(err, &statusError) {
+		return false
+	}
+	var payloadErrors payloadcms.Errors
+	if errors.As(err, &payloadErrors) {
+		return false
+	}
+
+	// The task was stopped on purpose
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// Scrape timeouts, LLM hangs, network errors, etc.
+	return true
+}
+
+// Stable error codes, these are part of the API so they must not change
+const (
+	ErrorCodeScrapeFailed   = "scrape_failed"
+	ErrorCodeCrawlFailed    = "crawl_failed"
+	ErrorCodeAgentFailed    = "agent_failed"
+	ErrorCodeCMSFailed      = "cms_failed"
+	ErrorCodeTimeout        = "timeout"
+	ErrorCodeCancelled      = "cancelled"
+	ErrorCodeChildrenFailed = "children_failed"
+	ErrorCodeInternal       = "internal"
+)
+
+// codedError attaches an error code to an error
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// WithCode attaches a stable error code to err, see ErrorCode
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// ErrorCode returns the stable code that best describes why a task failed with err
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	// Timeouts and cancellations can happen anywhere, they are more useful than where it happened
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCodeTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorCodeCancelled
+	}
+
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	var statusError *payloadcms.StatusError
+	if errors.As(err, &statusError) {
+		return ErrorCodeCMSFailed
+	}
+	var payloadErrors payloadcms.Errors
+	if errors.As(err, &payloadErrors) {
+		return ErrorCodeCMSFailed
+	}
+
+	return ErrorCodeInternal
+}
+
+// ErrorChain splits err into the message added by each wrapping error, outermost first.
+// "error converting pages: error running agent: overloaded" becomes
+// ["error converting pages", "error running agent", "overloaded"].
+func ErrorChain(err error) []string {
+	var chain []string
+	for err != nil {
+		message := err.Error()
+		next := errors.Unwrap(err)
+		if next != nil {
+			nextMessage := next.Error()
+			if message == nextMessage {
+				// Wrappers like codedError don't add a message
+				err = next
+				continue
+			}
+			message = strings.TrimSuffix(message, ": "+nextMessage)
+		}
+		chain = append(chain, message)
+		err = next
+	}
+	return chain
+}
@@ -0,0 +1,74 @@
+package agenttask
+
+import (
+	"context"
+
+	"github.com/docker/cagent/pkg/tools"
+)
+
+// Task phases reported in Progress
+const (
+	PhaseScraping   = "scraping"
+	PhaseCrawling   = "crawling"
+	PhaseConverting = "converting"
+	PhaseExporting  = "exporting"
+	// PhaseWaitingForLLM is a task waiting for the shared LLM limits, see llmlimit
+	PhaseWaitingForLLM = "waiting_for_llm"
+)
+
+// Progress describes how far along a task is
+type Progress struct {
+	Phase string `json:"phase"`
+	// Done and Total count pages for tasks that work on more than one page
+	Done       int    `json:"done,omitempty"`
+	Total      int    `json:"total,omitempty"`
+	CurrentURL string `json:"current_url,omitempty"`
+	// LLMWaitSeconds is how long the current agent run waited for the shared LLM limits so far
+	LLMWaitSeconds float64 `json:"llm_wait_seconds,omitempty"`
+}
+
+// Document actions reported in Document
+const (
+	DocumentCreated = "created"
+	DocumentUpdated = "updated"
+)
+
+// Document is a Payload document that a task created or updated
+type Document struct {
+	Collection string `json:"collection"`
+	ID         string `json:"id"`
+	Action     string `json:"action"`
+}
+
+// Checkpoint is a unit of work saved by an earlier attempt of a task, e.g. a page of a whole site conversion
+type Checkpoint struct {
+	// Value was saved with SaveCheckpoint, e.g. the id of a created page
+	Value string
+	// Done is set once the child spawned for the checkpoint completed
+	Done bool
+}
+
+// Reporter receives updates from a running task
+type Reporter interface {
+	ReportProgress(progress Progress)
+	ReportAgentEvent(event AgentEvent)
+	ReportDocument(document Document)
+	// SpawnChild queues task as a child of the running task for the unit of work key. Once Execute returns,
+	// the running task only finishes when all of its children have finished.
+	// Nothing is queued if the child of an earlier attempt for key is still unfinished or completed.
+	SpawnChild(key string, task AgentTask) error
+	// SaveCheckpoint records value for the unit of work key, so later attempts can skip it
+	SaveCheckpoint(key string, value string)
+	// Checkpoints returns the checkpoints saved by earlier attempts, by key
+	Checkpoints() map[string]Checkpoint
+}
+
+// reportOnToolCall reports progress every time the agent calls the tool
+func reportOnToolCall(tool tools.Tool, reporter Reporter, progress Progress) tools.Tool {
+	handler := tool.Handler
+	tool.Handler = func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+		reporter.ReportProgress(progress)
+		return handler(ctx, toolCall)
+	}
+	return tool
+}
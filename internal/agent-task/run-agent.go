@@ -0,0 +1,80 @@
+package agenttask
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ForTheChurch/buildforthechurch/internal/llmlimit"
+	"github.com/docker/cagent/pkg/runtime"
+	"github.com/docker/cagent/pkg/session"
+)
+
+// Agent event types reported in AgentEvent
+const (
+	AgentEventChoice           = "agent_choice"
+	AgentEventToolCall         = "tool_call"
+	AgentEventToolCallResponse = "tool_call_response"
+	AgentEventError            = "error"
+)
+
+// AgentEvent is an event from the agent runtime while a task runs
+type AgentEvent struct {
+	Type string `json:"type"`
+	// URL of the page the agent is working on
+	URL       string `json:"url,omitempty"`
+	Content   string `json:"content,omitempty"`
+	ToolName  string `json:"tool_name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Response  string `json:"response,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runAgent runs the session like rt.Run does, and forwards the runtime events to the reporter.
+// Time spent waiting for the shared LLM limits is reported as progress.
+func runAgent(ctx context.Context, rt *runtime.Runtime, sess *session.Session, reporter Reporter, url string) error {
+	var mu sync.Mutex
+	var llmWait time.Duration
+	ctx = llmlimit.WithWaitHooks(ctx, llmlimit.WaitHooks{
+		Waiting: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			reporter.ReportProgress(Progress{Phase: PhaseWaitingForLLM, CurrentURL: url, LLMWaitSeconds: llmWait.Seconds()})
+		},
+		Admitted: func(waited time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			llmWait += waited
+			reporter.ReportProgress(Progress{Phase: PhaseConverting, CurrentURL: url, LLMWaitSeconds: llmWait.Seconds()})
+		},
+	})
+
+	var runErr error
+	for event := range rt.RunStream(ctx, sess) {
+		switch e := event.(type) {
+		case *runtime.AgentChoiceEvent:
+			reporter.ReportAgentEvent(AgentEvent{Type: AgentEventChoice, URL: url, Content: e.Content})
+		case *runtime.ToolCallEvent:
+			reporter.ReportAgentEvent(AgentEvent{
+				Type:      AgentEventToolCall,
+				URL:       url,
+				ToolName:  e.ToolCall.Function.Name,
+				Arguments: e.ToolCall.Function.Arguments,
+			})
+		case *runtime.ToolCallResponseEvent:
+			reporter.ReportAgentEvent(AgentEvent{
+				Type:     AgentEventToolCallResponse,
+				URL:      url,
+				ToolName: e.ToolCall.Function.Name,
+				Response: e.Response,
+			})
+		case *runtime.ErrorEvent:
+			reporter.ReportAgentEvent(AgentEvent{Type: AgentEventError, URL: url, Error: e.Error})
+			if runErr == nil {
+				runErr = fmt.Errorf("%s", e.Error)
+			}
+		}
+	}
+	return runErr
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/docker/cagent/pkg/tools"
 )
 
-func toolUploadMedia(logTask string, payloadCMSClient *payloadcms.Client) tools.Tool {
+func toolUploadMedia(logTask string, payloadCMSClient *payloadcms.Client, reporter Reporter) tools.Tool {
 	return tools.Tool{
 		Handler: func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 			log.Println("[" + logTask + "] Upload media tool called")
@@ -41,6 +41,7 @@ func toolUploadMedia(logTask string, payloadCMSClient *payloadcms.Client) tools.
 			if err != nil {
 				return nil, fmt.Errorf("error uploading media: %w", err)
 			}
+			reporter.ReportDocument(Document{Collection: "media", ID: id, Action: DocumentCreated})
 
 			return &tools.ToolCallResult{
 				Output: "Media uploaded successfully. Media ID: " + id,
@@ -63,7 +64,7 @@ func toolUploadMedia(logTask string, payloadCMSClient *payloadcms.Client) tools.
 	}
 }
 
-func toolExportPage(logTask string, pageID string, payloadCMSClient *payloadcms.Client) tools.Tool {
+func toolExportPage(logTask string, pageID string, payloadCMSClient *payloadcms.Client, reporter Reporter) tools.Tool {
 	return tools.Tool{
 		Handler: func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 			log.Println("[" + logTask + "] Export page tool called")
@@ -93,6 +94,7 @@ func toolExportPage(logTask string, pageID string, payloadCMSClient *payloadcms.
 				log.Println("["+logTask+"] Error patching page:", err)
 				return nil, err
 			}
+			reporter.ReportDocument(Document{Collection: "pages", ID: pageID, Action: DocumentUpdated})
 
 			return &tools.ToolCallResult{
 				Output: "Page exported successfully",
@@ -115,7 +117,7 @@ func toolExportPage(logTask string, pageID string, payloadCMSClient *payloadcms.
 	}
 }
 
-func toolExportMarkdown(logTask string, postId, title, videoLink string, payloadCMSClient *payloadcms.Client) tools.Tool {
+func toolExportMarkdown(logTask string, postId, title, videoLink string, payloadCMSClient *payloadcms.Client, reporter Reporter) tools.Tool {
 	return tools.Tool{
 		Handler: func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 			log.Println("[" + logTask + "] Export markdown tool called")
@@ -136,6 +138,7 @@ func toolExportMarkdown(logTask string, postId, title, videoLink string, payload
 				log.Println("["+logTask+"] Error updating post markdown:", err)
 				return nil, err
 			}
+			reporter.ReportDocument(Document{Collection: "posts", ID: postId, Action: DocumentUpdated})
 
 			return &tools.ToolCallResult{
 				Output: "Markdown exported successfully",
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/ForTheChurch/buildforthechurch/internal/pagecache"
 	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
@@ -28,6 +27,8 @@ type YoutubeTranscriptTask struct {
 	llm              provider.Provider
 	transcriptCache  pagecache.PageCache
 	metadataCache    pagecache.PageCache
+	// refreshCache scrapes the video again even if it is cached
+	refreshCache bool
 }
 
 func NewYoutubeTranscriptTask(url string, postId string, firecrawlScraper scraper.Scraper, payloadCMSClient *payloadcms.Client, llm provider.Provider) *YoutubeTranscriptTask {
@@ -45,16 +46,42 @@ func NewYoutubeTranscriptTask(url string, postId string, firecrawlScraper scrape
 
 var _ AgentTask = &YoutubeTranscriptTask{}
 
+func init() {
+	Register(TaskTypeYoutubeTranscript, restoreYoutubeTranscriptTask)
+}
+
+func restoreYoutubeTranscriptTask(id string, params json.RawMessage, deps Dependencies) (AgentTask, error) {
+	var p YoutubeTranscriptParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("error unmarshalling youtube transcript params: %w", err)
+	}
+	t := NewYoutubeTranscriptTask(p.URL, p.PostID, deps.Scraper, deps.PayloadCMSClient, deps.LLM)
+	t.id = id
+	t.refreshCache = p.RefreshCache
+	return t, nil
+}
+
+// YoutubeTranscriptParams are the input parameters of a YoutubeTranscriptTask
+type YoutubeTranscriptParams struct {
+	URL          string `json:"url"`
+	PostID       string `json:"postId"`
+	RefreshCache bool   `json:"refreshCache,omitempty"`
+}
+
 func (t *YoutubeTranscriptTask) ID() string {
 	return t.id
 }
 
-func (t *YoutubeTranscriptTask) Execute(ctx context.Context) error {
-	log.Println("[YoutubeTranscriptTask] Started for", t.url)
+func (t *YoutubeTranscriptTask) Type() string {
+	return TaskTypeYoutubeTranscript
+}
+
+func (t *YoutubeTranscriptTask) Params() any {
+	return YoutubeTranscriptParams{URL: t.url, PostID: t.postId, RefreshCache: t.refreshCache}
+}
 
-	// 8 minutes, transcripts can be long
-	ctx, cancel := context.WithTimeout(ctx, 8*time.Minute)
-	defer cancel()
+func (t *YoutubeTranscriptTask) Execute(ctx context.Context, reporter Reporter) error {
+	log.Println("[YoutubeTranscriptTask] Started for", t.url)
 
 	var transcript string
 	var title string
@@ -66,21 +93,22 @@ func (t *YoutubeTranscriptTask) Execute(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("error getting cached metadata: %w", err)
 	}
-	if cachedTranscript != "" && cachedMetadata != "" {
+	if !t.refreshCache && cachedTranscript != "" && cachedMetadata != "" {
 		transcript = cachedTranscript
 		var metadata map[string]string
 		if err := json.Unmarshal([]byte(cachedMetadata), &metadata); err != nil {
-			return fmt.Errorf("error unmarshalling cached metadata: %w", err)
+			return Permanent(fmt.Errorf("error unmarshalling cached metadata: %w", err))
 		}
 		title = metadata["title"]
 
 		log.Println("[YoutubeTranscriptTask] Using cached transcript")
 	} else {
 		log.Println("[YoutubeTranscriptTask] No cached transcript or metadata found, scraping page")
+		reporter.ReportProgress(Progress{Phase: PhaseScraping, CurrentURL: t.url})
 		var metadata map[string]string
 		transcript, metadata, err = t.scrapeYoutubeTranscript(ctx)
 		if err != nil {
-			return fmt.Errorf("error scraping YouTube transcript: %w", err)
+			return WithCode(ErrorCodeScrapeFailed, fmt.Errorf("error scraping YouTube transcript: %w", err))
 		}
 
 		title = metadata["title"]
@@ -103,9 +131,11 @@ func (t *YoutubeTranscriptTask) Execute(ctx context.Context) error {
 
 	youtubeTranscriptPrompt, err := prompt.GetYoutubeTranscriptPrompt()
 	if err != nil {
-		return fmt.Errorf("error getting youtube transcript prompt: %w", err)
+		return Permanent(fmt.Errorf("error getting youtube transcript prompt: %w", err))
 	}
 
+	reporter.ReportProgress(Progress{Phase: PhaseConverting, CurrentURL: t.url})
+
 	ctx, b, cleanup := newBail(ctx)
 	defer cleanup()
 
@@ -115,22 +145,24 @@ func (t *YoutubeTranscriptTask) Execute(ctx context.Context) error {
 		agent.WithModel(t.llm),
 		agent.WithDescription("An agent that converts a sermon YouTube transcript into a formatted markdown document."),
 		agent.WithTools(
-			b.BailAfterSuccessfulToolCall(toolExportMarkdown("YoutubeTranscriptTask", t.postId, title, t.url, t.payloadCMSClient)),
+			b.BailAfterSuccessfulToolCall(reportOnToolCall(
+				toolExportMarkdown("YoutubeTranscriptTask", t.postId, title, t.url, t.payloadCMSClient, reporter),
+				reporter, Progress{Phase: PhaseExporting, CurrentURL: t.url})),
 		))
 
 	agentTeam := team.New(team.WithAgents(rootAgent))
 
 	rt, err := runtime.New(agentTeam)
 	if err != nil {
-		return fmt.Errorf("error creating runtime: %w", err)
+		return WithCode(ErrorCodeAgentFailed, fmt.Errorf("error creating runtime: %w", err))
 	}
 
 	p := "The title of the sermon is \"" + title + "\"\n\nBelow is the raw transcript:\n\n" + transcript
 	sess := session.New(session.WithUserMessage("", p))
 	sess.ToolsApproved = true
 
-	if _, err = rt.Run(ctx, sess); err != nil {
-		return fmt.Errorf("error running agent: %w", err)
+	if err = runAgent(ctx, rt, sess, reporter, t.url); err != nil {
+		return WithCode(ErrorCodeAgentFailed, fmt.Errorf("error running agent: %w", err))
 	}
 
 	log.Println("[YoutubeTranscriptTask] completed for", t.url)
@@ -0,0 +1,17 @@
+package llmlimit
+
+import "time"
+
+// Config is the limits of one process. Workers in other processes have limits of their own, so split
+// the provider's limits between the processes.
+type Config struct {
+	// MaxConcurrent is how many LLM requests run at the same time, 0 means no limit
+	MaxConcurrent int `env:"LLM_MAX_CONCURRENT" envDefault:"4"`
+	// TokensPerMinute limits the prompt tokens sent per minute, 0 means no limit
+	TokensPerMinute int `env:"LLM_TOKENS_PER_MINUTE"`
+	// MaxRetries is how many times a rate limited request is sent again
+	MaxRetries int `env:"LLM_RATE_LIMIT_RETRIES" envDefault:"5"`
+	// RetryBackoff is the first wait after a rate limited request when the provider doesn't say how long to wait.
+	// It doubles with every retry.
+	RetryBackoff time.Duration `env:"LLM_RATE_LIMIT_BACKOFF" envDefault:"10s"`
+}
@@ -0,0 +1,26 @@
+package llmlimit
+
+import (
+	"context"
+	"time"
+)
+
+// WaitHooks are called when a request made with the context has to wait for the limiter
+type WaitHooks struct {
+	// Waiting is called when the request starts waiting
+	Waiting func()
+	// Admitted is called once the request may be sent, with how long it waited
+	Admitted func(waited time.Duration)
+}
+
+type waitHooksKey struct{}
+
+// WithWaitHooks returns a context that reports the limiter waits of the requests made with it
+func WithWaitHooks(ctx context.Context, hooks WaitHooks) context.Context {
+	return context.WithValue(ctx, waitHooksKey{}, hooks)
+}
+
+func waitHooksFrom(ctx context.Context) WaitHooks {
+	hooks, _ := ctx.Value(waitHooksKey{}).(WaitHooks)
+	return hooks
+}
@@ -0,0 +1,176 @@
+package llmlimit
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiter caps concurrent requests and prompt tokens per minute, and pauses every request
+// after the provider rate limited one
+type limiter struct {
+	// slots has a value for each running request, nil if there is no limit
+	slots chan struct{}
+
+	tokensPerMinute int
+	maxRetries      int
+	retryBackoff    time.Duration
+
+	mu          sync.Mutex
+	tokens      float64
+	refilledAt  time.Time
+	pausedUntil time.Time
+}
+
+func newLimiter(cfg Config) *limiter {
+	l := &limiter{
+		tokensPerMinute: cfg.TokensPerMinute,
+		maxRetries:      cfg.MaxRetries,
+		retryBackoff:    cfg.RetryBackoff,
+		tokens:          float64(cfg.TokensPerMinute),
+		refilledAt:      time.Now(),
+	}
+	if cfg.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire waits until a request costing tokens may be sent. Call release once the request is done.
+func (l *limiter) acquire(ctx context.Context, tokens int) (release func(), err error) {
+	hooks := waitHooksFrom(ctx)
+	start := time.Now()
+	waited := false
+	waiting := func() {
+		if !waited && hooks.Waiting != nil {
+			hooks.Waiting()
+		}
+		waited = true
+	}
+
+	for {
+		wait := l.reserve(tokens)
+		if wait <= 0 {
+			break
+		}
+		waiting()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			waiting()
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	if waited && hooks.Admitted != nil {
+		hooks.Admitted(time.Since(start))
+	}
+
+	return func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}, nil
+}
+
+// reserve takes tokens from the bucket, or returns how long to wait before trying again
+func (l *limiter) reserve(tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.tokensPerMinute <= 0 {
+		return 0
+	}
+
+	perSecond := float64(l.tokensPerMinute) / 60
+	l.tokens = min(float64(l.tokensPerMinute), l.tokens+now.Sub(l.refilledAt).Seconds()*perSecond)
+	l.refilledAt = now
+
+	// Requests bigger than the whole budget wait for a full bucket instead of forever
+	cost := min(float64(tokens), float64(l.tokensPerMinute))
+	if l.tokens >= cost {
+		l.tokens -= cost
+		return 0
+	}
+	return time.Duration((cost - l.tokens) / perSecond * float64(time.Second))
+}
+
+// backOff pauses every request if err means the provider is rate limiting us.
+// It reports whether the failed request should be sent again.
+func (l *limiter) backOff(err error, attempt int) bool {
+	if !isRateLimited(err) || attempt >= l.maxRetries {
+		return false
+	}
+
+	wait, ok := retryAfter(err)
+	if !ok {
+		wait = l.retryBackoff << attempt
+	}
+	log.Println("[llmlimit] Rate limited, pausing LLM requests for", wait, "-", err)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pausedUntil := time.Now().Add(wait); pausedUntil.After(l.pausedUntil) {
+		l.pausedUntil = pausedUntil
+	}
+	return true
+}
+
+// The provider clients don't expose the response status, so rate limits are recognized by their message
+var rateLimitMessages = []string{"too many requests", "rate limit", "rate_limit", "overloaded"}
+
+// Matches the 429 and 529 statuses only where the message gives the status, e.g. "status code: 429",
+// "\"status\":529" or "HTTP 429", so other numbers with those digits don't count
+var rateLimitStatusPattern = regexp.MustCompile(`(?i)\b(?:status(?:[ _]?code)?|http(?:/[0-9.]+)?)"?\s*[:=]?\s*(?:429|529)\b`)
+
+func isRateLimited(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, m := range rateLimitMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return rateLimitStatusPattern.MatchString(message)
+}
+
+// Matches "Retry-After: 20", "retry after 1.5s" and "Please try again in 20s"
+var retryAfterPattern = regexp.MustCompile(`(?i)(?:retry[- _]after|try again in)[:= ]*([0-9]+(?:\.[0-9]+)?)\s*(ms|s|sec|seconds?)?\b`)
+
+// retryAfter finds how long the provider asked us to wait in the error message
+func retryAfter(err error) (time.Duration, bool) {
+	match := retryAfterPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+	n, parseErr := strconv.ParseFloat(match[1], 64)
+	if parseErr != nil {
+		return 0, false
+	}
+	if match[2] == "ms" {
+		return time.Duration(n * float64(time.Millisecond)), true
+	}
+	return time.Duration(n * float64(time.Second)), true
+}
@@ -0,0 +1,119 @@
+package llmlimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIsRateLimited(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"error, status code: 429, status: 429 Too Many Requests, message: Rate limit reached", true},
+		{`POST "https://api.anthropic.com/v1/messages": 529 Overloaded {"type":"overloaded_error"}`, true},
+		{`{"error":{"status":529,"message":"busy"}}`, true},
+		{"unexpected HTTP 429 from upstream", true},
+		{"rate_limit_error: too many tokens", true},
+		{"request req_4295 failed: context deadline exceeded", false},
+		{"dial tcp 10.0.0.1:5290: connection refused", false},
+		{"prompt is too long: 214291 tokens > 200000 maximum", false},
+		{"error, status code: 500, message: internal error", false},
+	}
+
+	for _, test := range tests {
+		if got := isRateLimited(errors.New(test.message)); got != test.want {
+			t.Errorf("isRateLimited(%q) = %v, want %v", test.message, got, test.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		message string
+		want    time.Duration
+		ok      bool
+	}{
+		{"429 Too Many Requests, Retry-After: 20", 20 * time.Second, true},
+		{"rate limited, retry after 1.5s", 1500 * time.Millisecond, true},
+		{"Rate limit reached. Please try again in 250ms.", 250 * time.Millisecond, true},
+		{"Please try again in 7 seconds", 7 * time.Second, true},
+		{"429 Too Many Requests", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := retryAfter(errors.New(test.message))
+		if got != test.want || ok != test.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", test.message, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	l := newLimiter(Config{TokensPerMinute: 600})
+
+	if wait := l.reserve(400); wait != 0 {
+		t.Fatalf("waited %s with a full budget", wait)
+	}
+	// 200 tokens are left and 10 come back every second
+	wait := l.reserve(300)
+	if wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("waits %s for 100 missing tokens, want about 10s", wait)
+	}
+	// Requests bigger than the budget wait for a full bucket
+	wait = l.reserve(6000)
+	if wait < 39*time.Second || wait > 40*time.Second {
+		t.Errorf("waits %s for a full bucket, want about 40s", wait)
+	}
+
+	if wait := newLimiter(Config{}).reserve(1_000_000); wait != 0 {
+		t.Errorf("waited %s without a token limit", wait)
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	rateLimited := errors.New("error, status code: 429, message: Rate limit reached")
+
+	t.Run("other errors", func(t *testing.T) {
+		l := newLimiter(Config{MaxRetries: 3, RetryBackoff: time.Second})
+		if l.backOff(errors.New("status code: 400, message: invalid request"), 0) {
+			t.Error("retried an invalid request")
+		}
+		if wait := l.reserve(1); wait != 0 {
+			t.Errorf("paused %s after an invalid request", wait)
+		}
+	})
+
+	t.Run("doubles the backoff", func(t *testing.T) {
+		l := newLimiter(Config{MaxRetries: 3, RetryBackoff: time.Second})
+		if !l.backOff(rateLimited, 2) {
+			t.Fatal("didn't retry a rate limited request")
+		}
+		if wait := l.reserve(1); wait < 3*time.Second || wait > 4*time.Second {
+			t.Errorf("paused %s on the third attempt, want about 4s", wait)
+		}
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		l := newLimiter(Config{MaxRetries: 3, RetryBackoff: time.Second})
+		if !l.backOff(errors.New("429 Too Many Requests, Retry-After: 30"), 0) {
+			t.Fatal("didn't retry a rate limited request")
+		}
+		if wait := l.reserve(1); wait < 29*time.Second || wait > 30*time.Second {
+			t.Errorf("paused %s, want about 30s", wait)
+		}
+		// A shorter backoff doesn't cut the pause short
+		l.backOff(rateLimited, 0)
+		if wait := l.reserve(1); wait < 29*time.Second {
+			t.Errorf("paused %s, want about 30s", wait)
+		}
+	})
+
+	t.Run("out of retries", func(t *testing.T) {
+		l := newLimiter(Config{MaxRetries: 3, RetryBackoff: time.Second})
+		if l.backOff(rateLimited, 3) {
+			t.Error("retried past MaxRetries")
+		}
+	})
+}
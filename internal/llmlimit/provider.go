@@ -0,0 +1,91 @@
+package llmlimit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/docker/cagent/pkg/chat"
+	"github.com/docker/cagent/pkg/model/provider"
+	"github.com/docker/cagent/pkg/tools"
+)
+
+// Provider shares one set of limits between every request made through it,
+// so concurrent tasks don't get throttled by the LLM provider
+type Provider struct {
+	provider.Provider
+	limiter *limiter
+}
+
+var _ provider.Provider = &Provider{}
+
+func New(cfg Config, llm provider.Provider) *Provider {
+	return &Provider{Provider: llm, limiter: newLimiter(cfg)}
+}
+
+func (p *Provider) CreateChatCompletionStream(ctx context.Context, messages []chat.Message, tools []tools.Tool) (chat.MessageStream, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := p.limiter.acquire(ctx, estimateTokens(messages))
+		if err != nil {
+			return nil, err
+		}
+
+		stream, err := p.Provider.CreateChatCompletionStream(ctx, messages, tools)
+		if err == nil {
+			// The slot is held until the response is read
+			return &limitedStream{MessageStream: stream, release: release}, nil
+		}
+		release()
+
+		if !p.limiter.backOff(err, attempt) {
+			return nil, err
+		}
+	}
+}
+
+func (p *Provider) CreateChatCompletion(ctx context.Context, messages []chat.Message) (string, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := p.limiter.acquire(ctx, estimateTokens(messages))
+		if err != nil {
+			return "", err
+		}
+
+		response, err := p.Provider.CreateChatCompletion(ctx, messages)
+		release()
+		if err == nil {
+			return response, nil
+		}
+
+		if !p.limiter.backOff(err, attempt) {
+			return "", err
+		}
+	}
+}
+
+// limitedStream releases its limiter slot once the stream ends or is closed
+type limitedStream struct {
+	chat.MessageStream
+	release func()
+	once    sync.Once
+}
+
+func (s *limitedStream) Recv() (chat.MessageStreamResponse, error) {
+	response, err := s.MessageStream.Recv()
+	if err != nil {
+		s.once.Do(s.release)
+	}
+	return response, err
+}
+
+func (s *limitedStream) Close() {
+	s.MessageStream.Close()
+	s.once.Do(s.release)
+}
+
+// estimateTokens guesses the prompt tokens of a request, about 4 characters per token
+func estimateTokens(messages []chat.Message) int {
+	var chars int
+	for _, message := range messages {
+		chars += len(message.Content)
+	}
+	return chars/4 + 1
+}
@@ -0,0 +1,212 @@
+package payloadcms
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultUserCollection is the auth collection of a new Payload app
+const defaultUserCollection = "users"
+
+// tokenRefreshMargin is how long before it expires a login token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+var ErrNoCredentials = errors.New("PAYLOAD_API_KEY or PAYLOAD_EMAIL and PAYLOAD_PASSWORD must be set")
+
+// Authenticator sets the credentials of the requests sent to Payload
+type Authenticator interface {
+	// Authorize sets the credentials on req
+	Authorize(ctx context.Context, req *http.Request) error
+	// Reauthenticate is called when Payload rejected the credentials of req with a 401. It reports
+	// whether Authorize now sets other credentials, so the request is worth sending again.
+	Reauthenticate(req *http.Request) bool
+}
+
+// NewAuthenticator logs in as a user if cfg has an email and password, otherwise it uses the API key
+func NewAuthenticator(cfg Config, client *http.Client) (Authenticator, error) {
+	if cfg.Email != "" || cfg.Password != "" {
+		if cfg.Email == "" || cfg.Password == "" {
+			return nil, fmt.Errorf("PAYLOAD_EMAIL and PAYLOAD_PASSWORD must both be set")
+		}
+		return NewLoginAuth(cfg.BaseURL, cfg.UserCollection, cfg.Email, cfg.Password, client), nil
+	}
+	if cfg.APIKey == "" {
+		return nil, ErrNoCredentials
+	}
+	return NewAPIKeyAuth(cfg.UserCollection, cfg.APIKey), nil
+}
+
+// APIKeyAuth authenticates with the API key of a user that has API keys enabled
+type APIKeyAuth struct {
+	header string
+}
+
+var _ Authenticator = &APIKeyAuth{}
+
+// NewAPIKeyAuth authenticates with key, an API key of a user in collection, "users" if empty
+func NewAPIKeyAuth(collection, key string) *APIKeyAuth {
+	return &APIKeyAuth{header: cmp.Or(collection, defaultUserCollection) + " API-Key " + key}
+}
+
+func (a *APIKeyAuth) Authorize(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", a.header)
+	return nil
+}
+
+// Reauthenticate reports false, a rejected API key stays rejected
+func (a *APIKeyAuth) Reauthenticate(req *http.Request) bool {
+	return false
+}
+
+// LoginAuth authenticates with the token of a user login. The token is refreshed before it
+// expires, and the user logs in again when Payload rejects it.
+type LoginAuth struct {
+	baseURL    string
+	collection string
+	email      string
+	password   string
+	client     *http.Client
+
+	token string
+	// refreshAt is when the token is refreshed, zero if Payload didn't say when it expires
+	refreshAt time.Time
+	mu        sync.Mutex
+}
+
+var _ Authenticator = &LoginAuth{}
+
+// NewLoginAuth logs in as the user of collection, "users" if empty, with email and password
+func NewLoginAuth(baseURL, collection, email, password string, client *http.Client) *LoginAuth {
+	return &LoginAuth{
+		baseURL:    baseURL,
+		collection: cmp.Or(collection, defaultUserCollection),
+		email:      email,
+		password:   password,
+		client:     client,
+	}
+}
+
+// loginResponse is the body of both the login and the refresh-token endpoints
+type loginResponse struct {
+	Response
+	Token          string `json:"token"`
+	RefreshedToken string `json:"refreshedToken"`
+	// Exp is when the token expires, in seconds since the epoch
+	Exp int64 `json:"exp"`
+}
+
+func (a *LoginAuth) Authorize(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && !a.refreshAt.IsZero() && time.Now().After(a.refreshAt) {
+		if err := a.refresh(ctx); err != nil {
+			// The token may have expired in the meantime, a new login replaces it
+			a.token = ""
+		}
+	}
+	if a.token == "" {
+		if err := a.login(ctx); err != nil {
+			return fmt.Errorf("error logging in to payload: %w", err)
+		}
+	}
+
+	req.Header.Set("Authorization", "JWT "+a.token)
+	return nil
+}
+
+// Reauthenticate drops the token of req so Authorize logs in again, unless another request already did
+func (a *LoginAuth) Reauthenticate(req *http.Request) bool {
+	rejected := req.Header.Get("Authorization")
+	if rejected == "" {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && rejected == "JWT "+a.token {
+		a.token = ""
+	}
+	return true
+}
+
+// login gets a new token, a.mu must be held
+func (a *LoginAuth) login(ctx context.Context) error {
+	credentials := map[string]string{"email": a.email, "password": a.password}
+	response, err := a.post(ctx, "login", credentials, "")
+	if err != nil {
+		return err
+	}
+	if response.Token == "" {
+		return fmt.Errorf("no token returned")
+	}
+	a.setToken(response.Token, response.Exp)
+	return nil
+}
+
+// refresh swaps the token for one that expires later, a.mu must be held
+func (a *LoginAuth) refresh(ctx context.Context) error {
+	response, err := a.post(ctx, "refresh-token", nil, a.token)
+	if err != nil {
+		return err
+	}
+	if response.RefreshedToken == "" {
+		return fmt.Errorf("no token returned")
+	}
+	a.setToken(response.RefreshedToken, response.Exp)
+	return nil
+}
+
+func (a *LoginAuth) setToken(token string, exp int64) {
+	a.token = token
+	a.refreshAt = time.Time{}
+	if exp > 0 {
+		// Short-lived tokens are refreshed halfway through their life instead
+		expiresAt := time.Unix(exp, 0)
+		a.refreshAt = expiresAt.Add(-min(tokenRefreshMargin, time.Until(expiresAt)/2))
+	}
+}
+
+func (a *LoginAuth) post(ctx context.Context, operation string, body any, token string) (loginResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return loginResponse{}, err
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	endpoint := a.baseURL + "/api/" + a.collection + "/" + operation
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, reqBody)
+	if err != nil {
+		return loginResponse{}, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "JWT "+token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var response loginResponse
+	if err := decodeResponse(resp, &response); err != nil {
+		return loginResponse{}, err
+	}
+	return response, nil
+}
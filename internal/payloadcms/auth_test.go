@@ -0,0 +1,154 @@
+package payloadcms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// payloadServer is a test server for a Payload app that logs users in
+type payloadServer struct {
+	t *testing.T
+	// exp is when the tokens it hands out expire
+	exp time.Time
+	// posts responds to the post requests of a token, it responds with the post if nil
+	posts func(w http.ResponseWriter, token string)
+
+	logins, refreshes int
+	// tokens are the tokens of the post requests
+	tokens []string
+}
+
+func (s *payloadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/users/login":
+		var credentials map[string]string
+		json.NewDecoder(r.Body).Decode(&credentials)
+		if credentials["email"] != "admin@example.org" || credentials["password"] != "secret" {
+			s.t.Errorf("logged in with %v", credentials)
+		}
+		s.logins++
+		json.NewEncoder(w).Encode(loginResponse{Token: fmt.Sprintf("login-%d", s.logins), Exp: s.exp.Unix()})
+	case "/api/users/refresh-token":
+		s.refreshes++
+		json.NewEncoder(w).Encode(loginResponse{RefreshedToken: fmt.Sprintf("refresh-%d", s.refreshes), Exp: s.exp.Unix()})
+	case "/api/posts/1":
+		token := r.Header.Get("Authorization")
+		s.tokens = append(s.tokens, token)
+		if s.posts != nil {
+			s.posts(w, token)
+			return
+		}
+		w.Write([]byte(`{"id":"1","title":"Easter"}`))
+	default:
+		s.t.Errorf("unexpected request %s", r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newLoginClient returns a client of server that logs in, and its authenticator
+func newLoginClient(t *testing.T, server *payloadServer) (*Client, *LoginAuth) {
+	t.Helper()
+	server.t = t
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	auth := NewLoginAuth(httpServer.URL, "", "admin@example.org", "secret", httpServer.Client())
+	c := NewClient(Config{BaseURL: httpServer.URL}, httpServer.Client(), WithAuthenticator(auth))
+	return c, auth
+}
+
+func TestLoginAuthLogsIn(t *testing.T) {
+	server := &payloadServer{exp: time.Now().Add(time.Hour)}
+	c, auth := newLoginClient(t, server)
+
+	for range 2 {
+		if _, err := c.GetPost(context.Background(), "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if server.logins != 1 {
+		t.Errorf("logged in %d times, want 1", server.logins)
+	}
+	for _, token := range server.tokens {
+		if token != "JWT login-1" {
+			t.Errorf("sent %q, want the token of the login", token)
+		}
+	}
+	if want := time.Unix(server.exp.Unix(), 0).Add(-tokenRefreshMargin); !auth.refreshAt.Equal(want) {
+		t.Errorf("refreshes at %s, want %s", auth.refreshAt, want)
+	}
+}
+
+func TestLoginAuthRefreshesBeforeExpiry(t *testing.T) {
+	server := &payloadServer{exp: time.Now().Add(time.Hour)}
+	c, auth := newLoginClient(t, server)
+
+	if _, err := c.GetPost(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	// The token gets close to expiring
+	auth.refreshAt = time.Now().Add(-time.Second)
+	if _, err := c.GetPost(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.logins != 1 || server.refreshes != 1 {
+		t.Errorf("logged in %d times and refreshed %d times, want once each", server.logins, server.refreshes)
+	}
+	if last := server.tokens[len(server.tokens)-1]; last != "JWT refresh-1" {
+		t.Errorf("sent %q, want the refreshed token", last)
+	}
+}
+
+func TestLoginAuthLogsInAgainAfter401(t *testing.T) {
+	server := &payloadServer{exp: time.Now().Add(time.Hour)}
+	// The first token was revoked
+	server.posts = func(w http.ResponseWriter, token string) {
+		if token == "JWT login-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"message":"You are not allowed to perform this action."}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"1","title":"Easter"}`))
+	}
+	c, _ := newLoginClient(t, server)
+
+	post, err := c.GetPost(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Easter" {
+		t.Errorf("got title %q, want Easter", post.Title)
+	}
+	if server.logins != 2 || len(server.tokens) != 2 {
+		t.Errorf("logged in %d times and sent %d requests, want 2 of each", server.logins, len(server.tokens))
+	}
+}
+
+func TestLoginAuthKeepsTokenAfter403(t *testing.T) {
+	server := &payloadServer{exp: time.Now().Add(time.Hour)}
+	server.posts = func(w http.ResponseWriter, token string) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":[{"message":"You are not allowed to perform this action."}]}`))
+	}
+	c, auth := newLoginClient(t, server)
+
+	_, err := c.GetPost(context.Background(), "1")
+	var unauthorized *Unauthorized
+	if !errors.As(err, &unauthorized) || unauthorized.StatusCode != http.StatusForbidden {
+		t.Fatalf("got %v, want Unauthorized with status 403", err)
+	}
+	if server.logins != 1 || len(server.tokens) != 1 {
+		t.Errorf("logged in %d times and sent %d requests, want 1 of each", server.logins, len(server.tokens))
+	}
+	if auth.token != "login-1" {
+		t.Errorf("token is %q, want it kept", auth.token)
+	}
+}
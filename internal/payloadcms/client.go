@@ -4,22 +4,45 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"mime"
-	"mime/multipart"
+	"log"
 	"net/http"
-	"net/textproto"
-	"path/filepath"
+	"net/url"
+	"time"
 )
 
 type Client struct {
 	cfg    Config
 	client *http.Client
+	auth   Authenticator
+	hooks  Hooks
 }
 
-func NewClient(cfg Config, client *http.Client) *Client {
-	return &Client{cfg: cfg, client: client}
+// Option configures a Client
+type Option func(c *Client)
+
+// WithAuthenticator sets the credentials of requests with auth instead of the API key of the config
+func WithAuthenticator(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithHooks observes the requests the client sends
+func WithHooks(hooks Hooks) Option {
+	return func(c *Client) {
+		c.hooks = hooks
+	}
+}
+
+func NewClient(cfg Config, client *http.Client, options ...Option) *Client {
+	c := &Client{cfg: cfg, client: client, auth: NewAPIKeyAuth(cfg.UserCollection, cfg.APIKey)}
+	for _, option := range options {
+		option(c)
+	}
+	return c
 }
 
 func (c *Client) UpdatePostMarkdown(ctx context.Context, postId, title, videoLink, markdown string) error {
@@ -33,34 +56,7 @@ func (c *Client) UpdatePostMarkdown(ctx context.Context, postId, title, videoLin
 	params.Title = title
 	params.VideoLink = videoLink
 
-	jsonBody, err := json.Marshal(params)
-	if err != nil {
-		return err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+"/api/posts/"+postId+"/content/markdown", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "users API-Key "+c.cfg.APIKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return err
-	}
-	if len(response.Errors) > 0 {
-		return response.Errors
-	}
-
-	return nil
+	return c.doJSON(ctx, http.MethodPost, "posts/"+postId+"/content/markdown", nil, params, nil)
 }
 
 func (c *Client) CreatePage(ctx context.Context, title string, slug string) (string, error) {
@@ -92,143 +88,180 @@ func (c *Client) CreatePage(ctx context.Context, title string, slug string) (str
 		},
 	}
 
-	jsonBody, err := json.Marshal(params)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+"/api/pages", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "users API-Key "+c.cfg.APIKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
 	var response PageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := c.doJSON(ctx, http.MethodPost, "pages", nil, params, &response); err != nil {
 		return "", err
 	}
-	if len(response.Errors) > 0 {
-		return "", response.Errors
+	if response.Doc == nil {
+		return "", fmt.Errorf("no document returned")
 	}
 
 	return response.Doc.ID, nil
 }
 
 func (c *Client) UpdatePageRaw(ctx context.Context, pageContent string, pageId string) error {
-	req, err := http.NewRequestWithContext(ctx, "PATCH", c.cfg.BaseURL+"/api/pages/"+pageId, bytes.NewBuffer([]byte(pageContent)))
-	if err != nil {
-		return err
-	}
+	return c.do(ctx, http.MethodPatch, c.endpoint("pages/"+pageId, nil), []byte(pageContent), "application/json", nil)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "users API-Key "+c.cfg.APIKey)
+func (c *Client) UpdatePage(ctx context.Context, page PagePatch) error {
+	return c.doJSON(ctx, http.MethodPatch, "pages/"+page.ID, nil, page, nil)
+}
 
-	resp, err := c.client.Do(req)
+func (c *Client) UploadMedia(ctx context.Context, filename string, media []byte) (string, error) {
+	doc, err := c.CreateMedia(ctx, filename, media, Media{})
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
+	return doc.ID, nil
+}
 
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return err
+func (c *Client) endpoint(path string, query url.Values) string {
+	endpoint := c.cfg.BaseURL + "/api/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
 	}
-	if len(response.Errors) > 0 {
-		return response.Errors
+	return endpoint
+}
+
+// doJSON sends body as JSON to the Payload REST API and decodes the response into out, unless out is nil
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, body any, out any) error {
+	var jsonBody []byte
+	var contentType string
+	if body != nil {
+		var err error
+		jsonBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
 	}
+	return c.do(ctx, method, c.endpoint(path, query), jsonBody, contentType, out)
+}
 
-	return nil
+// do sends a request and decodes the response into out, unless out is nil. Requests that are safe to
+// send twice are sent again when they fail with a network error or a 5xx, and any request is sent
+// again once with new credentials when Payload rejects its credentials with a 401.
+func (c *Client) do(ctx context.Context, method, endpoint string, body []byte, contentType string, out any) error {
+	reauthenticated := false
+	retries := 0
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, endpoint, body, contentType)
+		if err != nil {
+			return err
+		}
+		err = c.auth.Authorize(ctx, req)
+		if err == nil {
+			err = c.send(req, attempt, out)
+		}
+		if err == nil {
+			return nil
+		}
+
+		// The token expired or was revoked, e.g. log in again. A 403 means the credentials are fine but
+		// not allowed to do this, new ones wouldn't be either.
+		var unauthorized *Unauthorized
+		if errors.As(err, &unauthorized) && unauthorized.StatusCode == http.StatusUnauthorized &&
+			!reauthenticated && c.auth.Reauthenticate(req) {
+			reauthenticated = true
+			continue
+		}
+
+		if retries >= c.cfg.MaxRetries || !idempotent(method) || !retryable(ctx, err) {
+			return err
+		}
+		wait := c.cfg.RetryBackoff << retries
+		retries++
+		log.Println("[payloadcms]", method, endpoint, "failed, retrying in", wait, "-", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
 
-func (c *Client) UpdatePage(ctx context.Context, page PagePatch) error {
-	jsonBody, err := json.Marshal(page)
-	if err != nil {
-		return err
+func (c *Client) newRequest(ctx context.Context, method, endpoint string, body []byte, contentType string) (*http.Request, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", c.cfg.BaseURL+"/api/pages/"+page.ID, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "users API-Key "+c.cfg.APIKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
 
+func (c *Client) send(req *http.Request, attempt int, out any) error {
+	if c.hooks.OnRequest != nil {
+		c.hooks.OnRequest(req, attempt)
+	}
+	start := time.Now()
 	resp, err := c.client.Do(req)
+	if c.hooks.OnResponse != nil {
+		c.hooks.OnResponse(req, resp, err, time.Since(start))
+	}
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return err
-	}
-	if len(response.Errors) > 0 {
-		return response.Errors
-	}
-
-	return nil
+	return decodeResponse(resp, out)
 }
 
-func (c *Client) UploadMedia(ctx context.Context, filename string, media []byte) (string, error) {
-	var b bytes.Buffer
-	w := multipart.NewWriter(&b)
-
-	mimeType := mime.TypeByExtension(filepath.Ext(filename))
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
-	}
-
-	h := make(textproto.MIMEHeader)
-	h.Set("Content-Disposition", multipart.FileContentDisposition("file", filename))
-	h.Set("Content-Type", mimeType)
-	fw, err := w.CreatePart(h)
+// decodeResponse returns the error matching the response status or the errors reported by Payload,
+// or decodes the body into out
+func decodeResponse(resp *http.Response, out any) error {
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("create file part: %w", err)
+		return err
 	}
 
-	_, err = io.Copy(fw, bytes.NewReader(media))
-	if err != nil {
-		return "", fmt.Errorf("copy media: %w", err)
+	if resp.StatusCode >= 400 {
+		return newStatusError(resp.StatusCode, data)
 	}
 
-	w.Close()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+"/api/media", &b)
-	if err != nil {
-		return "", fmt.Errorf("new request: %w", err)
+	var response Response
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return response.Errors
 	}
 
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	req.Header.Set("Authorization", "users API-Key "+c.cfg.APIKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("do request: %w", err)
+	if out == nil {
+		return nil
 	}
-	defer resp.Body.Close()
+	return json.Unmarshal(data, out)
+}
 
-	var response MediaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("decode response: %w", err)
+// idempotent reports whether sending a request twice has the same effect as sending it once. Payload
+// only sets the given fields on PATCH, so updates are safe to send again; creates are not.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
 	}
+	return false
+}
 
-	if len(response.Errors) > 0 {
-		return "", response.Errors
+// retryable reports whether a request that failed with err may succeed when sent again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
 	}
 
-	if response.Doc == nil {
-		return "", fmt.Errorf("no document returned")
+	var serverError *ServerError
+	if errors.As(err, &serverError) {
+		return true
 	}
 
-	return response.Doc.ID, nil
+	// The connection failed or was dropped before a response was read
+	var urlError *url.Error
+	return errors.As(err, &urlError)
 }
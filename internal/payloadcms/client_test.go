@@ -0,0 +1,136 @@
+package payloadcms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const badGatewayPage = "<html><body><h1>502 Bad Gateway</h1></body></html>"
+
+func TestServerErrorRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		send       func(c *Client) error
+		want       int
+	}{
+		{
+			name:       "patch is retried",
+			maxRetries: 2,
+			send: func(c *Client) error {
+				_, err := c.UpdatePost(context.Background(), Post{ID: "1", Title: "Easter"})
+				return err
+			},
+			want: 3,
+		},
+		{
+			name:       "retries honour MaxRetries",
+			maxRetries: 4,
+			send: func(c *Client) error {
+				return c.UpdatePage(context.Background(), PagePatch{ID: "1"})
+			},
+			want: 5,
+		},
+		{
+			name:       "post isn't retried",
+			maxRetries: 2,
+			send: func(c *Client) error {
+				_, err := c.CreatePost(context.Background(), Post{Title: "Easter"})
+				return err
+			},
+			want: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			c := newTestClient(t, Config{MaxRetries: test.maxRetries, RetryBackoff: time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(badGatewayPage))
+			})
+
+			err := test.send(c)
+			var serverError *ServerError
+			if !errors.As(err, &serverError) {
+				t.Fatalf("got %v, want a ServerError", err)
+			}
+			if serverError.StatusCode != http.StatusBadGateway || serverError.Body != badGatewayPage {
+				t.Errorf("got status %d and body %q", serverError.StatusCode, serverError.Body)
+			}
+			if requests != test.want {
+				t.Errorf("sent %d requests, want %d", requests, test.want)
+			}
+		})
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, Config{MaxRetries: 3, RetryBackoff: time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"1","title":"Easter"}`))
+	})
+
+	post, err := c.GetPost(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Easter" || requests != 2 {
+		t.Errorf("got %q after %d requests", post.Title, requests)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	c := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"name":"ValidationError","message":"The following field is invalid: title","data":{"errors":[{"path":"title","message":"This field is required.","label":"Title"}]}}]}`))
+	})
+
+	_, err := c.CreatePost(context.Background(), Post{})
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	want := []FieldError{{Path: "title", Message: "This field is required.", Label: "Title"}}
+	if !reflect.DeepEqual(validationError.Fields, want) {
+		t.Errorf("got fields %+v, want %+v", validationError.Fields, want)
+	}
+	var payloadErrors Errors
+	if !errors.As(err, &payloadErrors) || payloadErrors[0].Name != "ValidationError" {
+		t.Errorf("got errors %v, want the ValidationError reported by payload", payloadErrors)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, Config{MaxRetries: 2, RetryBackoff: time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"message":"Not Found"}]}`))
+	})
+
+	_, err := c.GetPost(context.Background(), "missing")
+	var notFound *NotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+	var statusError *StatusError
+	if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want a StatusError with status 404", statusError)
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want 1", requests)
+	}
+}
@@ -0,0 +1,164 @@
+package payloadcms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// The slugs of the collections modelled in page.go
+const (
+	CollectionPages      = "pages"
+	CollectionPosts      = "posts"
+	CollectionEvents     = "events"
+	CollectionSeries     = "series"
+	CollectionCategories = "categories"
+	CollectionForms      = "forms"
+	CollectionMedia      = "media"
+)
+
+var ErrMissingID = errors.New("document has no id")
+
+// DocResponse is the body Payload returns when it creates, updates or deletes a document
+type DocResponse[T any] struct {
+	Response
+	Doc     *T     `json:"doc,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// shallow asks Payload not to populate relationships, so fields like Post.HeroImage hold the id
+// they are typed as instead of the related document
+var shallow = url.Values{"depth": {"0"}}
+
+func getDocument[T any](ctx context.Context, c *Client, collection, id string) (T, error) {
+	var doc T
+	if id == "" {
+		return doc, ErrMissingID
+	}
+	err := c.doJSON(ctx, http.MethodGet, collection+"/"+url.PathEscape(id), shallow, nil, &doc)
+	return doc, err
+}
+
+func createDocument[T any](ctx context.Context, c *Client, collection string, doc T) (T, error) {
+	var response DocResponse[T]
+	if err := c.doJSON(ctx, http.MethodPost, collection, shallow, doc, &response); err != nil {
+		var zero T
+		return zero, err
+	}
+	return documentOf(response)
+}
+
+func updateDocument[T any](ctx context.Context, c *Client, collection, id string, doc T) (T, error) {
+	var response DocResponse[T]
+	if id == "" {
+		var zero T
+		return zero, ErrMissingID
+	}
+	if err := c.doJSON(ctx, http.MethodPatch, collection+"/"+url.PathEscape(id), shallow, doc, &response); err != nil {
+		var zero T
+		return zero, err
+	}
+	return documentOf(response)
+}
+
+func deleteDocument(ctx context.Context, c *Client, collection, id string) error {
+	if id == "" {
+		return ErrMissingID
+	}
+	return c.doJSON(ctx, http.MethodDelete, collection+"/"+url.PathEscape(id), shallow, nil, nil)
+}
+
+func documentOf[T any](response DocResponse[T]) (T, error) {
+	if response.Doc == nil {
+		var zero T
+		return zero, fmt.Errorf("no document returned")
+	}
+	return *response.Doc, nil
+}
+
+func (c *Client) GetPost(ctx context.Context, id string) (Post, error) {
+	return getDocument[Post](ctx, c, CollectionPosts, id)
+}
+
+func (c *Client) CreatePost(ctx context.Context, post Post) (Post, error) {
+	return createDocument(ctx, c, CollectionPosts, post)
+}
+
+// UpdatePost saves post over the post with the same id
+func (c *Client) UpdatePost(ctx context.Context, post Post) (Post, error) {
+	return updateDocument(ctx, c, CollectionPosts, post.ID, post)
+}
+
+func (c *Client) DeletePost(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionPosts, id)
+}
+
+func (c *Client) GetEvent(ctx context.Context, id string) (Event, error) {
+	return getDocument[Event](ctx, c, CollectionEvents, id)
+}
+
+func (c *Client) CreateEvent(ctx context.Context, event Event) (Event, error) {
+	return createDocument(ctx, c, CollectionEvents, event)
+}
+
+// UpdateEvent saves event over the event with the same id
+func (c *Client) UpdateEvent(ctx context.Context, event Event) (Event, error) {
+	return updateDocument(ctx, c, CollectionEvents, event.ID, event)
+}
+
+func (c *Client) DeleteEvent(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionEvents, id)
+}
+
+func (c *Client) GetSeries(ctx context.Context, id string) (Series, error) {
+	return getDocument[Series](ctx, c, CollectionSeries, id)
+}
+
+func (c *Client) CreateSeries(ctx context.Context, series Series) (Series, error) {
+	return createDocument(ctx, c, CollectionSeries, series)
+}
+
+// UpdateSeries saves series over the series with the same id
+func (c *Client) UpdateSeries(ctx context.Context, series Series) (Series, error) {
+	return updateDocument(ctx, c, CollectionSeries, series.ID, series)
+}
+
+func (c *Client) DeleteSeries(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionSeries, id)
+}
+
+func (c *Client) GetCategory(ctx context.Context, id string) (Category, error) {
+	return getDocument[Category](ctx, c, CollectionCategories, id)
+}
+
+func (c *Client) CreateCategory(ctx context.Context, category Category) (Category, error) {
+	return createDocument(ctx, c, CollectionCategories, category)
+}
+
+// UpdateCategory saves category over the category with the same id
+func (c *Client) UpdateCategory(ctx context.Context, category Category) (Category, error) {
+	return updateDocument(ctx, c, CollectionCategories, category.ID, category)
+}
+
+func (c *Client) DeleteCategory(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionCategories, id)
+}
+
+func (c *Client) GetForm(ctx context.Context, id string) (Form, error) {
+	return getDocument[Form](ctx, c, CollectionForms, id)
+}
+
+func (c *Client) CreateForm(ctx context.Context, form Form) (Form, error) {
+	return createDocument(ctx, c, CollectionForms, form)
+}
+
+// UpdateForm saves form over the form with the same id
+func (c *Client) UpdateForm(ctx context.Context, form Form) (Form, error) {
+	return updateDocument(ctx, c, CollectionForms, form.ID, form)
+}
+
+func (c *Client) DeleteForm(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionForms, id)
+}
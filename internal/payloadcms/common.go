@@ -1,6 +1,9 @@
 package payloadcms
 
-import "strings"
+import (
+	"encoding/json"
+	"strings"
+)
 
 type Response struct {
 	Errors Errors `json:"errors,omitempty"`
@@ -9,7 +12,10 @@ type Response struct {
 type Errors []Error
 
 type Error struct {
+	Name    string `json:"name,omitempty"`
 	Message string `json:"message"`
+	// Data holds details of the error, e.g. the field errors of a validation error
+	Data json.RawMessage `json:"data,omitempty"`
 }
 
 func (e Errors) Error() string {
@@ -1,6 +1,21 @@
 package payloadcms
 
+import "time"
+
 type Config struct {
 	BaseURL string `env:"PAYLOAD_BASE_URL,required"`
-	APIKey  string `env:"PAYLOAD_API_KEY,required"`
+	// APIKey is the API key of a user with API keys enabled. Set Email and Password instead to log in
+	// as a user.
+	APIKey   string `env:"PAYLOAD_API_KEY"`
+	Email    string `env:"PAYLOAD_EMAIL"`
+	Password string `env:"PAYLOAD_PASSWORD"`
+	// UserCollection is the auth collection of the user
+	UserCollection string `env:"PAYLOAD_USER_COLLECTION" envDefault:"users"`
+	// MaxRetries is how many times a request that is safe to send twice is sent again after a network
+	// error or a 5xx
+	MaxRetries int `env:"PAYLOAD_MAX_RETRIES" envDefault:"3"`
+	// RetryBackoff is the wait before the first retry, it doubles with every retry
+	RetryBackoff time.Duration `env:"PAYLOAD_RETRY_BACKOFF" envDefault:"500ms"`
+	// LogRequests logs every request sent to Payload with its status and duration
+	LogRequests bool `env:"PAYLOAD_LOG_REQUESTS"`
 }
@@ -0,0 +1,137 @@
+package payloadcms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is how much of a body that isn't JSON is kept in a StatusError
+const maxErrorBody = 512
+
+// StatusError is a response with an error status. Statuses with a meaning of their own are returned
+// as NotFound, Unauthorized, ValidationError or ServerError, which all unwrap to their StatusError.
+type StatusError struct {
+	StatusCode int
+	// Errors are the errors reported by Payload, empty if the body wasn't JSON, e.g. the error page of
+	// a proxy
+	Errors Errors
+	// Body is the start of a body that wasn't JSON
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	message := e.Errors.Error()
+	if message == "" {
+		message = e.Body
+	}
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("payload returned %d: %s", e.StatusCode, message)
+}
+
+// Unwrap returns the errors reported by Payload, if any
+func (e *StatusError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors
+}
+
+// NotFound means the document or collection doesn't exist
+type NotFound struct {
+	StatusError
+}
+
+func (e *NotFound) Unwrap() error {
+	return &e.StatusError
+}
+
+// Unauthorized means the credentials are missing, wrong, expired or not allowed to do this
+type Unauthorized struct {
+	StatusError
+}
+
+func (e *Unauthorized) Unwrap() error {
+	return &e.StatusError
+}
+
+// ValidationError means Payload rejected the data that was sent
+type ValidationError struct {
+	StatusError
+	// Fields are the errors of each invalid field
+	Fields []FieldError
+}
+
+// FieldError is why a field of a document is invalid
+type FieldError struct {
+	// Path is the path of the field, e.g. "layout.0.columns"
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Label   string `json:"label,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.StatusError.Error()
+	}
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.Path + ": " + field.Message
+	}
+	return e.StatusError.Error() + " (" + strings.Join(fields, ", ") + ")"
+}
+
+func (e *ValidationError) Unwrap() error {
+	return &e.StatusError
+}
+
+// ServerError means Payload, or a proxy in front of it, failed to handle the request. The same
+// request may succeed later.
+type ServerError struct {
+	StatusError
+}
+
+func (e *ServerError) Unwrap() error {
+	return &e.StatusError
+}
+
+// newStatusError returns the error type that matches status, with the errors reported in body
+func newStatusError(status int, body []byte) error {
+	statusError := StatusError{StatusCode: status}
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		statusError.Body = strings.TrimSpace(string(body[:min(len(body), maxErrorBody)]))
+	} else {
+		statusError.Errors = response.Errors
+	}
+
+	switch {
+	case status == http.StatusNotFound:
+		return &NotFound{statusError}
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return &Unauthorized{statusError}
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return &ValidationError{StatusError: statusError, Fields: fieldErrors(statusError.Errors)}
+	case status >= 500:
+		return &ServerError{statusError}
+	}
+	return &statusError
+}
+
+// fieldErrors collects the field errors Payload reports in the data of validation errors
+func fieldErrors(errs Errors) []FieldError {
+	var fields []FieldError
+	for _, err := range errs {
+		var data struct {
+			Errors []FieldError `json:"errors"`
+		}
+		if len(err.Data) == 0 || json.Unmarshal(err.Data, &data) != nil {
+			continue
+		}
+		fields = append(fields, data.Errors...)
+	}
+	return fields
+}
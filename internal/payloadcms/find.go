@@ -0,0 +1,160 @@
+package payloadcms
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Where is a Payload where query. Build it with Equals, NotEquals, In, Like, And and Or, e.g.
+//
+//	And(Equals("_status", "published"), Or(Like("title", "easter"), In("categories", id1, id2)))
+type Where map[string]any
+
+func Equals(field string, value any) Where {
+	return Where{field: Where{"equals": value}}
+}
+
+func NotEquals(field string, value any) Where {
+	return Where{field: Where{"not_equals": value}}
+}
+
+// In matches documents where field is one of values, or for has-many fields holds one of them
+func In(field string, values ...any) Where {
+	return Where{field: Where{"in": values}}
+}
+
+// Like matches documents where field contains every word of value, case-insensitive
+func Like(field string, value string) Where {
+	return Where{field: Where{"like": value}}
+}
+
+// And matches documents that match every condition
+func And(conditions ...Where) Where {
+	return Where{"and": conditions}
+}
+
+// Or matches documents that match any of the conditions
+func Or(conditions ...Where) Where {
+	return Where{"or": conditions}
+}
+
+// encode adds the query in Payload's bracket syntax, e.g. where[or][0][title][like]=easter
+func (w Where) encode(values url.Values, prefix string) {
+	for key, value := range w {
+		encodeValue(values, prefix+"["+key+"]", value)
+	}
+}
+
+func encodeValue(values url.Values, key string, value any) {
+	switch value := value.(type) {
+	case Where:
+		value.encode(values, key)
+	case map[string]any:
+		Where(value).encode(values, key)
+	case []Where:
+		for i, condition := range value {
+			condition.encode(values, key+"["+strconv.Itoa(i)+"]")
+		}
+	case []any:
+		for i, item := range value {
+			encodeValue(values, key+"["+strconv.Itoa(i)+"]", item)
+		}
+	case []string:
+		for i, item := range value {
+			values.Set(key+"["+strconv.Itoa(i)+"]", item)
+		}
+	case string:
+		values.Set(key, value)
+	case time.Time:
+		values.Set(key, value.Format(time.RFC3339Nano))
+	case nil:
+		values.Set(key, "null")
+	default:
+		values.Set(key, fmt.Sprint(value))
+	}
+}
+
+// Query selects and pages the documents returned by Find
+type Query struct {
+	Where Where
+	// Limit is the number of documents per page, Payload defaults to 10
+	Limit int
+	// Page starts at 1
+	Page int
+	// Sort is the field to sort by, prefixed with - to sort descending, e.g. "-publishedAt"
+	Sort string
+	// Depth populates relationships this many levels deep. The types in page.go hold ids, so
+	// only use it with a T that accepts the related documents.
+	Depth  int
+	Locale string
+}
+
+func (q Query) values() url.Values {
+	values := url.Values{"depth": {strconv.Itoa(q.Depth)}}
+	if q.Where != nil {
+		q.Where.encode(values, "where")
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Page > 0 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.Sort != "" {
+		values.Set("sort", q.Sort)
+	}
+	if q.Locale != "" {
+		values.Set("locale", q.Locale)
+	}
+	return values
+}
+
+// FindResult is a page of documents
+type FindResult[T any] struct {
+	Docs          []T  `json:"docs"`
+	TotalDocs     int  `json:"totalDocs"`
+	Limit         int  `json:"limit"`
+	TotalPages    int  `json:"totalPages"`
+	Page          int  `json:"page"`
+	PagingCounter int  `json:"pagingCounter"`
+	HasPrevPage   bool `json:"hasPrevPage"`
+	HasNextPage   bool `json:"hasNextPage"`
+	PrevPage      *int `json:"prevPage"`
+	NextPage      *int `json:"nextPage"`
+}
+
+// Find returns the page of documents of collection that match query
+func Find[T any](ctx context.Context, c *Client, collection string, query Query) (FindResult[T], error) {
+	var result FindResult[T]
+	err := c.doJSON(ctx, http.MethodGet, collection, query.values(), nil, &result)
+	return result, err
+}
+
+// FindAll walks every page of documents of collection that match query, starting at query.Page. It
+// stops after the first error.
+func FindAll[T any](ctx context.Context, c *Client, collection string, query Query) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			result, err := Find[T](ctx, c, collection, query)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, doc := range result.Docs {
+				if !yield(doc, nil) {
+					return
+				}
+			}
+			if !result.HasNextPage || result.NextPage == nil {
+				return
+			}
+			query.Page = *result.NextPage
+		}
+	}
+}
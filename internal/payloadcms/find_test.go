@@ -0,0 +1,141 @@
+package payloadcms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of a test server that handles requests with handler
+func newTestClient(t *testing.T, cfg Config, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	cfg.APIKey = "key"
+	return NewClient(cfg, server.Client())
+}
+
+func TestQueryValues(t *testing.T) {
+	published := time.Date(2026, 4, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query Query
+		want  url.Values
+	}{
+		{
+			name:  "empty",
+			query: Query{},
+			want:  url.Values{"depth": {"0"}},
+		},
+		{
+			name:  "equals",
+			query: Query{Where: Equals("_status", "published"), Limit: 20, Page: 2, Sort: "-publishedAt"},
+			want: url.Values{
+				"depth":                  {"0"},
+				"where[_status][equals]": {"published"},
+				"limit":                  {"20"},
+				"page":                   {"2"},
+				"sort":                   {"-publishedAt"},
+			},
+		},
+		{
+			name:  "or",
+			query: Query{Where: Or(Like("title", "easter"), NotEquals("slug", "home"))},
+			want: url.Values{
+				"depth":                          {"0"},
+				"where[or][0][title][like]":      {"easter"},
+				"where[or][1][slug][not_equals]": {"home"},
+			},
+		},
+		{
+			name:  "in",
+			query: Query{Where: In("categories", "a", "b", 3)},
+			want: url.Values{
+				"depth":                    {"0"},
+				"where[categories][in][0]": {"a"},
+				"where[categories][in][1]": {"b"},
+				"where[categories][in][2]": {"3"},
+			},
+		},
+		{
+			name:  "nested",
+			query: Query{Where: And(Equals("series", nil), Or(Equals("publishedAt", published))), Depth: 1, Locale: "es"},
+			want: url.Values{
+				"depth":                         {"1"},
+				"locale":                        {"es"},
+				"where[and][0][series][equals]": {"null"},
+				"where[and][1][or][0][publishedAt][equals]": {"2026-04-05T10:00:00Z"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.query.values(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	type doc struct {
+		ID string `json:"id"`
+	}
+	pages := [][]doc{{{ID: "1"}, {ID: "2"}}, {{ID: "3"}, {ID: "4"}}, {{ID: "5"}}}
+
+	var requested []string
+	c := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/posts" || r.URL.Query().Get("where[_status][equals]") != "published" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		requested = append(requested, r.URL.Query().Get("page"))
+
+		result := FindResult[doc]{Docs: pages[page-1], Page: page, TotalPages: len(pages), HasNextPage: page < len(pages)}
+		if result.HasNextPage {
+			next := page + 1
+			result.NextPage = &next
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+
+	query := Query{Where: Equals("_status", "published"), Limit: 2, Page: 1}
+	var ids []string
+	for doc, err := range FindAll[doc](context.Background(), c, CollectionPosts, query) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, doc.ID)
+	}
+
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested pages %v, want %v", requested, want)
+	}
+}
+
+func TestFindAllStopsEarly(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		next := 2
+		json.NewEncoder(w).Encode(FindResult[Post]{Docs: []Post{{ID: "1"}, {ID: "2"}}, HasNextPage: true, NextPage: &next})
+	})
+
+	for range FindAll[Post](context.Background(), c, CollectionPosts, Query{}) {
+		break
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want 1", requests)
+	}
+}
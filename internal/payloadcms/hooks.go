@@ -0,0 +1,27 @@
+package payloadcms
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// Hooks observe the requests sent to Payload, e.g. to log them. Both are optional.
+type Hooks struct {
+	// OnRequest is called before each attempt of a request, attempt is 0 for the first
+	OnRequest func(req *http.Request, attempt int)
+	// OnResponse is called after each attempt with its response, or the error if there is no response.
+	// The body of resp must not be read.
+	OnResponse func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+}
+
+// LogHooks logs every response, or the error of requests that got none
+var LogHooks = Hooks{
+	OnResponse: func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+		if err != nil {
+			log.Println("[payloadcms]", req.Method, req.URL.Path, "failed after", elapsed, "-", err)
+			return
+		}
+		log.Println("[payloadcms]", req.Method, req.URL.Path, resp.StatusCode, elapsed)
+	},
+}
@@ -1,11 +1,70 @@
 package payloadcms
 
-type MediaResponse struct {
-	Response
-	Doc *MediaDocument `json:"doc,omitempty"`
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+)
+
+// CreateMedia uploads a file with the fields of media, e.g. its alt text
+func (c *Client) CreateMedia(ctx context.Context, filename string, data []byte, media Media) (Media, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", multipart.FileContentDisposition("file", filename))
+	h.Set("Content-Type", mimeType)
+	fw, err := w.CreatePart(h)
+	if err != nil {
+		return Media{}, fmt.Errorf("create file part: %w", err)
+	}
+
+	_, err = io.Copy(fw, bytes.NewReader(data))
+	if err != nil {
+		return Media{}, fmt.Errorf("copy media: %w", err)
+	}
+
+	// Payload reads the other fields of an upload from the _payload part
+	fields, err := json.Marshal(media)
+	if err != nil {
+		return Media{}, err
+	}
+	if err := w.WriteField("_payload", string(fields)); err != nil {
+		return Media{}, fmt.Errorf("write fields: %w", err)
+	}
+
+	w.Close()
+
+	// Uploads are not sent again on failure, they would create the media twice
+	var response DocResponse[Media]
+	if err := c.do(ctx, http.MethodPost, c.endpoint(CollectionMedia, shallow), b.Bytes(), w.FormDataContentType(), &response); err != nil {
+		return Media{}, err
+	}
+	return documentOf(response)
+}
+
+func (c *Client) GetMedia(ctx context.Context, id string) (Media, error) {
+	return getDocument[Media](ctx, c, CollectionMedia, id)
+}
+
+// UpdateMedia saves the fields of media, e.g. its alt text, over the media with the same id. The
+// file itself can't be replaced.
+func (c *Client) UpdateMedia(ctx context.Context, media Media) (Media, error) {
+	return updateDocument(ctx, c, CollectionMedia, media.ID, media)
 }
 
-type MediaDocument struct {
-	ID  string `json:"id"`
-	Url string `json:"url"`
+func (c *Client) DeleteMedia(ctx context.Context, id string) error {
+	return deleteDocument(ctx, c, CollectionMedia, id)
 }
@@ -105,8 +105,8 @@ type Post struct {
 	PopulatedAuthors []PopulatedAuthor `json:"populatedAuthors,omitempty"`
 	Slug             *string           `json:"slug,omitempty"`
 	SlugLock         *bool             `json:"slugLock,omitempty"`
-	UpdatedAt        string            `json:"updatedAt"`
-	CreatedAt        string            `json:"createdAt"`
+	UpdatedAt        string            `json:"updatedAt,omitempty"`
+	CreatedAt        string            `json:"createdAt,omitempty"`
 	Status           *string           `json:"_status,omitempty"`
 }
 
@@ -124,8 +124,8 @@ type Event struct {
 	PublishedAt *string  `json:"publishedAt,omitempty"`
 	Slug        *string  `json:"slug,omitempty"`
 	SlugLock    *bool    `json:"slugLock,omitempty"`
-	UpdatedAt   string   `json:"updatedAt"`
-	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt,omitempty"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
 	Status      *string  `json:"_status,omitempty"`
 }
 
@@ -137,11 +137,11 @@ type PopulatedAuthor struct {
 
 // Media represents a media file in the CMS
 type Media struct {
-	ID           string      `json:"id"`
+	ID           string      `json:"id,omitempty"`
 	Alt          *string     `json:"alt,omitempty"`
 	Caption      *RichText   `json:"caption,omitempty"`
-	UpdatedAt    string      `json:"updatedAt"`
-	CreatedAt    string      `json:"createdAt"`
+	UpdatedAt    string      `json:"updatedAt,omitempty"`
+	CreatedAt    string      `json:"createdAt,omitempty"`
 	URL          *string     `json:"url,omitempty"`
 	ThumbnailURL *string     `json:"thumbnailURL,omitempty"`
 	Filename     *string     `json:"filename,omitempty"`
@@ -183,8 +183,8 @@ type Series struct {
 	Description string  `json:"description"`
 	Slug        *string `json:"slug,omitempty"`
 	SlugLock    *bool   `json:"slugLock,omitempty"`
-	UpdatedAt   string  `json:"updatedAt"`
-	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt,omitempty"`
+	CreatedAt   string  `json:"createdAt,omitempty"`
 }
 
 // Category represents a content category
@@ -195,8 +195,8 @@ type Category struct {
 	SlugLock    *bool           `json:"slugLock,omitempty"`
 	Parent      interface{}     `json:"parent,omitempty"` // string | Category
 	Breadcrumbs []CategoryCrumb `json:"breadcrumbs,omitempty"`
-	UpdatedAt   string          `json:"updatedAt"`
-	CreatedAt   string          `json:"createdAt"`
+	UpdatedAt   string          `json:"updatedAt,omitempty"`
+	CreatedAt   string          `json:"createdAt,omitempty"`
 }
 
 // CategoryCrumb represents a breadcrumb item for categories
@@ -331,7 +331,7 @@ type FormBlock struct {
 
 // Form represents a form in the CMS
 type Form struct {
-	ID                  string        `json:"id"`
+	ID                  string        `json:"id,omitempty"`
 	Title               string        `json:"title"`
 	Fields              []FormField   `json:"fields,omitempty"`
 	SubmitButtonLabel   *string       `json:"submitButtonLabel,omitempty"`
@@ -339,8 +339,8 @@ type Form struct {
 	ConfirmationMessage *RichText     `json:"confirmationMessage,omitempty"`
 	Redirect            *FormRedirect `json:"redirect,omitempty"`
 	Emails              []FormEmail   `json:"emails,omitempty"`
-	UpdatedAt           string        `json:"updatedAt"`
-	CreatedAt           string        `json:"createdAt"`
+	UpdatedAt           string        `json:"updatedAt,omitempty"`
+	CreatedAt           string        `json:"createdAt,omitempty"`
 }
 
 // FormField represents a form field (union type)
@@ -0,0 +1,21 @@
+package worker
+
+import (
+	agentmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/ForTheChurch/buildforthechurch/internal/gloo"
+	"github.com/ForTheChurch/buildforthechurch/internal/llmlimit"
+	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
+	"github.com/ForTheChurch/buildforthechurch/internal/scraper"
+)
+
+// Config is the configuration needed to run tasks, shared by the API and cmd/worker
+type Config struct {
+	Gloo       gloo.Config
+	Scraper    scraper.FirecrawlConfig
+	PayloadCMS payloadcms.Config
+	Tasks      agentmanager.Config
+	LLM        llmlimit.Config
+
+	// AgentAPIKey authenticates requests to the API and signs callbacks
+	AgentAPIKey string `env:"AGENT_API_KEY,required"`
+}
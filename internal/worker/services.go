@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	agenttask "github.com/ForTheChurch/buildforthechurch/internal/agent-task"
+	agenttaskmanager "github.com/ForTheChurch/buildforthechurch/internal/agent-task-manager"
+	"github.com/ForTheChurch/buildforthechurch/internal/gloo"
+	"github.com/ForTheChurch/buildforthechurch/internal/llmlimit"
+	"github.com/ForTheChurch/buildforthechurch/internal/payloadcms"
+	"github.com/ForTheChurch/buildforthechurch/internal/scraper"
+	latest "github.com/docker/cagent/pkg/config/v2"
+	"github.com/docker/cagent/pkg/environment"
+	"github.com/docker/cagent/pkg/model/provider"
+	"github.com/docker/cagent/pkg/model/provider/anthropic"
+	"github.com/docker/cagent/pkg/model/provider/openai"
+)
+
+// Services are the services that run tasks, shared by the API and cmd/worker
+type Services struct {
+	payloadCMSClient *payloadcms.Client
+	scraper          scraper.Scraper
+	agentTaskManager *agenttaskmanager.AgentTaskManager
+	taskStore        agenttaskmanager.TaskStore
+	taskQueue        agenttaskmanager.QueueBackend
+	llm              provider.Provider
+}
+
+// New builds the services that run tasks and starts the task manager's workers
+func New(ctx context.Context, cfg Config) (*Services, error) {
+	scraper, err := scraper.NewFirecrawl(cfg.Scraper)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gloo fails with usage tracking enabled
+	trackUsage := false
+
+	var llm provider.Provider
+	if os.Getenv("USE_ANTHROPIC_API") == "true" {
+		if os.Getenv("ANTHROPIC_API_KEY") == "" {
+			log.Fatal("ANTHROPIC_API_KEY is not set")
+		}
+		llm, err = anthropic.NewClient(
+			ctx,
+			&latest.ModelConfig{
+				Provider:   "anthropic",
+				Model:      "claude-sonnet-4-5",
+				MaxTokens:  64000,
+				TrackUsage: &trackUsage,
+			},
+			environment.NewOsEnvProvider(),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// Gloo mimics the openai API best
+		llm, err = openai.NewClient(
+			ctx,
+			&latest.ModelConfig{
+				Provider:   "openai",
+				Model:      "us.anthropic.claude-sonnet-4-20250514-v1:0",
+				BaseURL:    gloo.BaseURL,
+				MaxTokens:  64000,
+				TrackUsage: &trackUsage,
+			},
+			gloo.NewProvider(cfg.Gloo),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Every task shares the same LLM limits
+	llm = llmlimit.New(cfg.LLM, llm)
+
+	payloadAuth, err := payloadcms.NewAuthenticator(cfg.PayloadCMS, http.DefaultClient)
+	if err != nil {
+		return nil, err
+	}
+	payloadOptions := []payloadcms.Option{payloadcms.WithAuthenticator(payloadAuth)}
+	if cfg.PayloadCMS.LogRequests {
+		payloadOptions = append(payloadOptions, payloadcms.WithHooks(payloadcms.LogHooks))
+	}
+	payloadCMSClient := payloadcms.NewClient(cfg.PayloadCMS, http.DefaultClient, payloadOptions...)
+
+	taskStore, taskQueue, err := agenttaskmanager.OpenStore(cfg.Tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := agenttask.Dependencies{
+		Scraper:          scraper,
+		PayloadCMSClient: payloadCMSClient,
+		LLM:              llm,
+	}
+	agentTaskManager := agenttaskmanager.New(cfg.Tasks, taskStore, taskQueue, func(id string, taskType string, params json.RawMessage) (agenttask.AgentTask, error) {
+		return agenttask.Restore(id, taskType, params, deps)
+	}, agenttaskmanager.NewCallbackNotifier(cfg.AgentAPIKey, http.DefaultClient))
+	agentTaskManager.Start(ctx)
+
+	return &Services{
+		payloadCMSClient: payloadCMSClient,
+		scraper:          scraper,
+		agentTaskManager: agentTaskManager,
+		taskStore:        taskStore,
+		taskQueue:        taskQueue,
+		llm:              llm,
+	}, nil
+}
+
+// Drain stops accepting tasks and waits until ctx is done for running tasks to finish, see
+// AgentTaskManager.Drain
+func (s *Services) Drain(ctx context.Context) error {
+	return s.agentTaskManager.Drain(ctx)
+}
+
+// Close releases the resources held by the services
+func (s *Services) Close() error {
+	return errors.Join(s.taskQueue.Close(), s.taskStore.Close())
+}
+
+func (s *Services) GetPayloadCMSClient() *payloadcms.Client {
+	return s.payloadCMSClient
+}
+
+func (s *Services) GetScraper() scraper.Scraper {
+	return s.scraper
+}
+
+func (s *Services) GetAgentTaskManager() *agenttaskmanager.AgentTaskManager {
+	return s.agentTaskManager
+}
+
+func (s *Services) GetLLM() provider.Provider {
+	return s.llm
+}
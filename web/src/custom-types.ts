@@ -21,7 +21,14 @@ export interface BeginConversionRequest {
   }
 }
 
-export type TaskStatus = 'queued' | 'running' | 'completed' | 'failed' | 'idle'
+export type TaskStatus =
+  | 'queued'
+  | 'running'
+  | 'completed'
+  | 'completed_with_errors'
+  | 'failed'
+  | 'cancelled'
+  | 'idle'
 
 export interface ApiError {
   message: string
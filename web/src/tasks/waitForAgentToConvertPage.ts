@@ -56,9 +56,13 @@ export const waitForAgentToConvertPageConfig = {
 
       // Status schema:
       // {
-      //   "task_status": "queued" | "running" | "completed" | "failed"
+      //   "task_status": "queued" | "running" | "completed" | "failed" | "cancelled"
       // }
-      if (agentTaskStatus == 'completed' || agentTaskStatus == 'failed') {
+      if (
+        agentTaskStatus == 'completed' ||
+        agentTaskStatus == 'failed' ||
+        agentTaskStatus == 'cancelled'
+      ) {
         return {
           output: { status: agentTaskStatus },
         }
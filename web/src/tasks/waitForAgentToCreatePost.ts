@@ -36,9 +36,13 @@ export const waitForAgentToCreatePostConfig = {
 
       // Status schema:
       // {
-      //   "task_status": "queued" | "running" | "completed" | "failed"
+      //   "task_status": "queued" | "running" | "completed" | "failed" | "cancelled"
       // }
-      if (agentTaskStatus == 'completed' || agentTaskStatus == 'failed') {
+      if (
+        agentTaskStatus == 'completed' ||
+        agentTaskStatus == 'failed' ||
+        agentTaskStatus == 'cancelled'
+      ) {
         return {
           output: { status: agentTaskStatus },
         }
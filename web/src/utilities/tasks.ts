@@ -18,8 +18,12 @@ export const getStatusClass = (status: string): string => {
       return 'convert-status-running'
     case 'completed':
       return 'convert-status-completed'
+    case 'completed_with_errors':
+      return 'convert-status-completed-with-errors'
     case 'failed':
       return 'convert-status-failed'
+    case 'cancelled':
+      return 'convert-status-cancelled'
     default:
       return 'convert-status-idle'
   }
@@ -33,8 +37,12 @@ export const getStatusIcon = (status: string): string => {
       return '🔄'
     case 'completed':
       return '✅'
+    case 'completed_with_errors':
+      return '⚠️'
     case 'failed':
       return '❌'
+    case 'cancelled':
+      return '🚫'
     default:
       return '⭕'
   }